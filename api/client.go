@@ -32,22 +32,28 @@ const Version = "1.0.0"
 // Client is a client for the V1 API
 type Client struct {
 	client *http.Client
+	stream *http.Client
 }
 
 // NewClient will return a new ClientV1 for the local unix socket, suitable
 // for communicating with the daemon.
 func NewClient(address string) *Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial("unix", address)
+		},
+		DisableKeepAlives:     false,
+		IdleConnTimeout:       30 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
 	return &Client{
 		client: &http.Client{
-			Transport: &http.Transport{
-				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-					return net.Dial("unix", address)
-				},
-				DisableKeepAlives:     false,
-				IdleConnTimeout:       30 * time.Second,
-				ExpectContinueTimeout: 1 * time.Second,
-			},
-			Timeout: 60 * time.Second,
+			Transport: transport,
+			Timeout:   60 * time.Second,
+		},
+		// Event streams stay open for as long as they are needed
+		stream: &http.Client{
+			Transport: transport,
 		},
 	}
 }
@@ -71,23 +77,37 @@ func (c *Client) runJob(req *http.Request) (j *jobs.Job, err error) {
 		return
 	}
 	// Read back the Job ID
-	id, err := readID(resp)
+	id, merged, err := readID(resp)
 	if err != nil {
 		return
 	}
+	if merged {
+		fmt.Printf("Waiting on job %d, an identical job which was already queued\n", id)
+	}
+	return c.waitJob(id)
+}
+
+// waitJob follows the events for a job until it has finished, then reads it back
+func (c *Client) waitJob(id int) (j *jobs.Job, err error) {
 	start := time.Now()
 	for {
-		// wait
-		time.Sleep(time.Second)
-		// request
-		if j, err = c.GetJob(id); err != nil {
+		// Subscribe before checking the job, so that no changes are missed
+		var s *EventStream
+		if s, err = c.Events(id); err != nil {
 			return
 		}
-		// Stop if job is finished
-		if j.Status > jobs.Running {
+		// Stop if job is already finished
+		if j, err = c.GetJob(id); err != nil || j.Status > jobs.Running {
+			s.Close()
 			return
 		}
-		fmt.Printf("Elapsed Time: %s\n", time.Now().Sub(start).String())
+		finished := s.waitJob(start)
+		s.Close()
+		// Read back the final state of the job
+		if finished {
+			return c.GetJob(id)
+		}
+		// The stream was dropped before the job finished, so start over
 	}
 }
 
@@ -96,8 +116,32 @@ func (c *Client) runDiff(req *http.Request) (d *repo.Diff, j *jobs.Job, err erro
 	if j, err = c.runJob(req); err != nil {
 		return
 	}
+	d, err = readDiff(j)
+	return
+}
+
+// readDiff decodes the Diff stored in the results of a finished job
+func readDiff(j *jobs.Job) (d *repo.Diff, err error) {
+	d = &repo.Diff{}
+	// Failed jobs don't have a Diff
+	if len(j.Results) == 0 {
+		return
+	}
 	if err = d.UnmarshalBinary(j.Results); err != nil {
 		err = fmt.Errorf("error while decoding diff: %v", err)
 	}
 	return
 }
+
+// readSummary decodes the Summary stored in the results of a finished job
+func readSummary(j *jobs.Job) (s *repo.Summary, err error) {
+	// Failed jobs don't have a Summary
+	if len(j.Results) == 0 {
+		return
+	}
+	s = &repo.Summary{}
+	if err = s.UnmarshalBinary(j.Results); err != nil {
+		err = fmt.Errorf("error while decoding summary: %v", err)
+	}
+	return
+}
@@ -17,11 +17,61 @@
 package v1
 
 import (
+	"context"
 	"fmt"
 	"github.com/valyala/fasthttp"
 	"net/http"
+	"strconv"
 )
 
+// modifyDaemon sends an action to the daemon, along with any extra query parameters
+func (c *Client) modifyDaemon(client *http.Client, action string, args map[string]string) error {
+	// Create the request
+	req, err := http.NewRequest("PATCH", formURI("api/v1/daemon"), nil)
+	if err != nil {
+		return err
+	}
+	// Set the query parameters
+	q := req.URL.Query()
+	q.Add("action", action)
+	for k, v := range args {
+		q.Add(k, v)
+	}
+	req.URL.RawQuery = q.Encode()
+	// Send the request
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Check for failure
+	if resp.StatusCode != http.StatusOK {
+		return readError(resp.Body)
+	}
+	return nil
+}
+
+// SetWorkers asks the daemon to change the number of jobs which may run at once
+func (c *Client) SetWorkers(count int) error {
+	return c.modifyDaemon(c.client, "workers", map[string]string{"count": strconv.Itoa(count)})
+}
+
+// Pause asks the daemon to stop starting new jobs, and to hold incoming transit manifests
+func (c *Client) Pause() error {
+	return c.modifyDaemon(c.client, "pause", nil)
+}
+
+// Resume asks the daemon to start running jobs again, including any held transit manifests
+func (c *Client) Resume() error {
+	return c.modifyDaemon(c.client, "resume", nil)
+}
+
+// Drain asks the daemon to pause, and waits for the jobs which are still running to finish
+func (c *Client) Drain() error {
+	// Running jobs may take a very long time
+	return c.modifyDaemon(c.stream, "drain", nil)
+}
+
 // ModifyDaemon makes a requested change to the daemon
 func (l *Listener) ModifyDaemon(ctx *fasthttp.RequestCtx) {
 	// Get the "action" query argument
@@ -32,8 +82,45 @@ func (l *Listener) ModifyDaemon(ctx *fasthttp.RequestCtx) {
 	}
 	// Pivot by action
 	switch action {
+	case "drain":
+		l.drain(ctx)
+	case "pause":
+		l.store.Pause()
+	case "resume":
+		l.store.Resume()
+	case "workers":
+		l.setWorkers(ctx)
 	default:
 		writeErrorString(ctx, fmt.Sprintf("Action '%s' not implemented for the daemon", action), http.StatusBadRequest)
 	}
 	return
 }
+
+// drain pauses the queue and responds once there are no running jobs left
+func (l *Listener) drain(ctx *fasthttp.RequestCtx) {
+	// Give up if the daemon is shutting down
+	wait, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-l.done:
+			cancel()
+		case <-wait.Done():
+		}
+	}()
+	if err := l.store.Drain(wait); err != nil {
+		writeError(ctx, err, http.StatusConflict)
+	}
+}
+
+// setWorkers grows or shrinks the worker pool to the "count" query argument
+func (l *Listener) setWorkers(ctx *fasthttp.RequestCtx) {
+	count, err := strconv.Atoi(string(ctx.QueryArgs().Peek("count")))
+	if err != nil {
+		writeErrorString(ctx, "Count must be a number when setting workers", http.StatusBadRequest)
+		return
+	}
+	if err = l.manager.SetWorkers(count); err != nil {
+		writeError(ctx, err, http.StatusBadRequest)
+	}
+}
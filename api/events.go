@@ -0,0 +1,190 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package v1
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/getsolus/ferryd/jobs"
+	"github.com/valyala/fasthttp"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Heartbeat is the longest time an event stream stays quiet, so that closed clients are noticed
+const Heartbeat = 15 * time.Second
+
+// EventStream reads the Server-Sent Events from the daemon as they happen
+type EventStream struct {
+	body io.ReadCloser
+	in   *bufio.Reader
+}
+
+// Events opens a stream of Job and repo events, only for the Job "id" if it isn't 0
+func (c *Client) Events(id int) (s *EventStream, err error) {
+	// Create the request
+	req, err := http.NewRequest("GET", formURI("api/v1/events"), nil)
+	if err != nil {
+		return
+	}
+	// Set the query parameters
+	if id != 0 {
+		q := req.URL.Query()
+		q.Add("job", strconv.Itoa(id))
+		req.URL.RawQuery = q.Encode()
+	}
+	// Send the request
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return
+	}
+	// Check for failure
+	if resp.StatusCode != http.StatusOK {
+		err = readError(resp.Body)
+		resp.Body.Close()
+		return
+	}
+	s = &EventStream{
+		body: resp.Body,
+		in:   bufio.NewReader(resp.Body),
+	}
+	return
+}
+
+// Next waits for the next Event, returning io.EOF once the daemon ends the stream
+func (s *EventStream) Next() (ev jobs.Event, err error) {
+	var data []byte
+	for {
+		var line []byte
+		if line, err = s.in.ReadBytes('\n'); err != nil {
+			return
+		}
+		line = bytes.TrimRight(line, "\r\n")
+		switch {
+		case len(line) == 0:
+			// A blank line ends the event
+			if len(data) == 0 {
+				continue
+			}
+			err = json.Unmarshal(data, &ev)
+			return
+		case line[0] == ':':
+			// Comments only keep the connection alive
+		case bytes.HasPrefix(line, []byte("data:")):
+			data = append(data, bytes.TrimSpace(line[5:])...)
+		}
+	}
+}
+
+// Close ends the stream
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
+
+// waitJob prints the progress of a Job until it finishes, returning false if the stream ended first
+func (s *EventStream) waitJob(start time.Time) bool {
+	for {
+		ev, err := s.Next()
+		if err != nil {
+			return false
+		}
+		if ev.Kind == jobs.JobLogged {
+			fmt.Printf("Elapsed Time: %s, %s\n", time.Now().Sub(start).String(), ev)
+			continue
+		}
+		if ev.Job == nil {
+			continue
+		}
+		// Stop if job is finished
+		if ev.Job.Status > jobs.Running {
+			return true
+		}
+		fmt.Printf("Elapsed Time: %s, %s\n", time.Now().Sub(start).String(), ev)
+	}
+}
+
+// Events handles requests to stream Job and repo events as they happen
+func (l *Listener) Events(ctx *fasthttp.RequestCtx) {
+	// Get the optional Job ID
+	var id int
+	var err error
+	if raw := ctx.QueryArgs().Peek("job"); len(raw) > 0 {
+		if id, err = strconv.Atoi(string(raw)); err != nil {
+			writeError(ctx, err, http.StatusBadRequest)
+			return
+		}
+	}
+	// Subscribe now, so that no events are missed once the client sees the response
+	events := l.store.Events()
+	ch := events.Subscribe()
+	ctx.SetContentType("text/event-stream")
+	ctx.Response.Header.Set("Cache-Control", "no-cache")
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer events.Unsubscribe(ch)
+		heartbeat := time.NewTicker(Heartbeat)
+		defer heartbeat.Stop()
+		// Send the headers right away
+		if err := writeComment(w, "connected"); err != nil {
+			return
+		}
+		for {
+			select {
+			case <-l.done:
+				return
+			case <-heartbeat.C:
+				if err := writeComment(w, "heartbeat"); err != nil {
+					return
+				}
+			case ev, ok := <-ch:
+				// Closed if this client fell too far behind
+				if !ok {
+					return
+				}
+				if id != 0 && ev.JobID != id {
+					continue
+				}
+				if err := writeEvent(w, ev); err != nil {
+					return
+				}
+			}
+		}
+	})
+}
+
+// writeComment sends a comment line, which clients ignore
+func writeComment(w *bufio.Writer, comment string) error {
+	if _, err := fmt.Fprintf(w, ": %s\n\n", comment); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// writeEvent sends a single Event as JSON
+func writeEvent(w *bufio.Writer, ev jobs.Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Kind, data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -17,12 +17,15 @@
 package v1
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"github.com/getsolus/ferryd/jobs"
 	"github.com/valyala/fasthttp"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"os"
 	"strconv"
 )
 
@@ -63,6 +66,7 @@ func (l *Listener) GetJob(ctx *fasthttp.RequestCtx) {
 		writeError(ctx, err, http.StatusNotFound)
 		return
 	}
+	job.Summary = job.DescribeProgress()
 	// Encode the job as JSON in the HTTP body
 	enc := json.NewEncoder(ctx)
 	if err = enc.Encode(job); err != nil {
@@ -70,6 +74,119 @@ func (l *Listener) GetJob(ctx *fasthttp.RequestCtx) {
 	}
 }
 
+// CancelJob asks the daemon to cancel a Job, waiting for it to stop if it is already running
+func (c *Client) CancelJob(id int) (j *jobs.Job, err error) {
+	// Create the request
+	req, err := http.NewRequest("DELETE", formURI(fmt.Sprintf("api/v1/jobs/%d", id)), nil)
+	if err != nil {
+		return
+	}
+	// Send the request
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	// Check for failure
+	if resp.StatusCode != http.StatusOK {
+		err = readError(resp.Body)
+		return
+	}
+	// Decode the body as a job
+	dec := json.NewDecoder(resp.Body)
+	j = &jobs.Job{}
+	if err = dec.Decode(j); err != nil {
+		return
+	}
+	// Running jobs stop once they reach a safe point
+	if j.Status == jobs.Running {
+		j, err = c.waitJob(id)
+	}
+	return
+}
+
+// CancelJob handles requests to cancel a queued or running Job
+func (l *Listener) CancelJob(ctx *fasthttp.RequestCtx) {
+	// Get the Job ID
+	idString := ctx.UserValue("id").(string)
+	id, err := strconv.Atoi(idString)
+	if err != nil {
+		writeError(ctx, err, http.StatusBadRequest)
+		return
+	}
+	// Cancel the job
+	job, err := l.store.Cancel(id)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		writeError(ctx, err, http.StatusNotFound)
+		return
+	case jobs.ErrJobFinished:
+		writeError(ctx, err, http.StatusConflict)
+		return
+	default:
+		writeError(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	// Encode the job as JSON in the HTTP body
+	enc := json.NewEncoder(ctx)
+	if err = enc.Encode(job); err != nil {
+		writeError(ctx, err, http.StatusInternalServerError)
+	}
+}
+
+// Retry asks the daemon to queue a new copy of a failed Job, and waits for it to finish
+func (c *Client) Retry(id int) (j *jobs.Job, err error) {
+	// Create the request
+	req, err := http.NewRequest("PATCH", formURI(fmt.Sprintf("api/v1/jobs/%d", id)), nil)
+	if err != nil {
+		return
+	}
+	// Set the query parameters
+	q := req.URL.Query()
+	q.Add("action", "retry")
+	req.URL.RawQuery = q.Encode()
+	// wait for job to complete
+	j, err = c.runJob(req)
+	return
+}
+
+// ModifyJob handles requests to act on an existing Job
+func (l *Listener) ModifyJob(ctx *fasthttp.RequestCtx) {
+	// Get the Job ID
+	idString := ctx.UserValue("id").(string)
+	id, err := strconv.Atoi(idString)
+	if err != nil {
+		writeError(ctx, err, http.StatusBadRequest)
+		return
+	}
+	// Pivot by the requested action
+	var jobID int
+	var merged bool
+	switch action := string(ctx.QueryArgs().Peek("action")); action {
+	case "retry":
+		jobID, merged, err = l.store.Retry(id)
+	default:
+		writeErrorString(ctx, fmt.Sprintf("Invalid action '%s' when modifying job", action), http.StatusBadRequest)
+		return
+	}
+	// Check for any errors
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		writeError(ctx, err, http.StatusNotFound)
+		return
+	case jobs.ErrNotFailed:
+		writeError(ctx, err, http.StatusConflict)
+		return
+	default:
+		writeError(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	// Send back the ID of the created job
+	writeID(ctx, jobID, merged)
+}
+
 func (c *Client) resetJobs(status string) error {
 	// Create the request
 	req, err := http.NewRequest("DELETE", formURI("api/v1/jobs"), nil)
@@ -134,3 +251,122 @@ func (l *Listener) ResetJobs(ctx *fasthttp.RequestCtx) {
 		writeError(ctx, err, http.StatusInternalServerError)
 	}
 }
+
+// GetJobLog reads the log of a Job, starting "offset" bytes in
+func (c *Client) GetJobLog(id int, offset int64) (data []byte, err error) {
+	// Create the request
+	req, err := http.NewRequest("GET", formURI(fmt.Sprintf("api/v1/jobs/%d/log", id)), nil)
+	if err != nil {
+		return
+	}
+	// Set the query parameters
+	if offset > 0 {
+		q := req.URL.Query()
+		q.Add("offset", strconv.FormatInt(offset, 10))
+		req.URL.RawQuery = q.Encode()
+	}
+	// Send the request
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	// Check for failure
+	if resp.StatusCode != http.StatusOK {
+		err = readError(resp.Body)
+		return
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// FollowJobLog prints the log of a Job as it is written, until the Job has finished
+func (c *Client) FollowJobLog(id int, out io.Writer) error {
+	var offset int64
+	for {
+		// Subscribe before reading the log, so that no lines are missed
+		s, err := c.Events(id)
+		if err != nil {
+			return err
+		}
+		finished, err := c.followLog(s, id, &offset, out)
+		s.Close()
+		if err != nil || finished {
+			return err
+		}
+		// The stream was dropped before the job finished, so start over
+	}
+}
+
+// followLog copies any new lines in the log of a Job whenever it has an event, returning true once it has finished
+func (c *Client) followLog(s *EventStream, id int, offset *int64, out io.Writer) (bool, error) {
+	j, err := c.GetJob(id)
+	if err != nil {
+		return false, err
+	}
+	for {
+		// Catch up on the log
+		data, err := c.GetJobLog(id, *offset)
+		if err != nil {
+			return false, err
+		}
+		if _, err = out.Write(data); err != nil {
+			return false, err
+		}
+		*offset += int64(len(data))
+		// Stop if job is finished
+		if j.Status > jobs.Running {
+			return true, nil
+		}
+		// Wait for the job to log or change status
+		ev, err := s.Next()
+		if err != nil {
+			return false, nil
+		}
+		if ev.Job != nil {
+			j = ev.Job
+		}
+	}
+}
+
+// GetJobLog handles requests for the log of a Job, from an optional byte "offset"
+func (l *Listener) GetJobLog(ctx *fasthttp.RequestCtx) {
+	// Get the Job ID
+	idString := ctx.UserValue("id").(string)
+	id, err := strconv.Atoi(idString)
+	if err != nil {
+		writeError(ctx, err, http.StatusBadRequest)
+		return
+	}
+	// Get the optional offset
+	var offset int64
+	if raw := ctx.QueryArgs().Peek("offset"); len(raw) > 0 {
+		if offset, err = strconv.ParseInt(string(raw), 10, 64); err != nil || offset < 0 {
+			writeErrorString(ctx, fmt.Sprintf("Invalid log offset '%s'", raw), http.StatusBadRequest)
+			return
+		}
+	}
+	// Make sure the Job exists
+	if _, err = l.store.GetJob(id); err != nil {
+		writeError(ctx, err, http.StatusNotFound)
+		return
+	}
+	ctx.SetContentType("text/plain; charset=utf-8")
+	// Jobs which haven't started yet have no log
+	f, err := os.Open(jobs.LogPath(id))
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		writeError(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	// Send everything after the offset
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		writeError(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	if _, err = io.Copy(ctx, f); err != nil {
+		writeError(ctx, err, http.StatusInternalServerError)
+	}
+}
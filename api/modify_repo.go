@@ -44,9 +44,7 @@ func (c *Client) modifyDiff(id, action string) (d *repo.Diff, j *jobs.Job, err e
 	if j, err = c.modifyRepo(id, action); err != nil {
 		return
 	}
-	if err = d.UnmarshalBinary(j.Results); err != nil {
-		err = fmt.Errorf("error while decoding diff: %v", err)
-	}
+	d, err = readDiff(j)
 	return
 }
 
@@ -64,20 +62,26 @@ func (l *Listener) ModifyRepo(ctx *fasthttp.RequestCtx) {
 		writeErrorString(ctx, "Action required when modifying repo", http.StatusBadRequest)
 		return
 	}
+	// Get the optional priority
+	priority, err := readPriority(ctx)
+	if err != nil {
+		writeError(ctx, err, http.StatusBadRequest)
+		return
+	}
 	// Pivot by the requested action
-	var err error
 	var jobID int
+	var merged bool
 	switch action {
 	case "check":
-		jobID, err = l.manager.Check(id)
+		jobID, merged, err = l.manager.Check(id, priority)
 	case "delta":
-		jobID, err = l.manager.Delta(id)
+		jobID, merged, err = l.manager.Delta(id, priority)
 	case "index":
-		jobID, err = l.manager.Index(id)
+		jobID, merged, err = l.manager.Index(id, priority)
 	case "rescan":
-		jobID, err = l.manager.Rescan(id)
+		jobID, merged, err = l.manager.Rescan(id, priority)
 	case "trim-obsoletes":
-		jobID, err = l.manager.TrimObsoletes(id)
+		jobID, merged, err = l.manager.TrimObsoletes(id, priority)
 	case "trim-packages":
 		// Get the "max" query parameter
 		max := string(ctx.QueryArgs().Peek("max"))
@@ -85,12 +89,12 @@ func (l *Listener) ModifyRepo(ctx *fasthttp.RequestCtx) {
 			writeErrorString(ctx, "Max required when trimming packages", http.StatusBadRequest)
 			return
 		}
-		m, err := strconv.Atoi(max)
-		if err != nil {
+		m, convErr := strconv.Atoi(max)
+		if convErr != nil {
 			writeErrorString(ctx, "Max must be an integer", http.StatusBadRequest)
 			return
 		}
-		jobID, err = l.manager.TrimPackages(id, m)
+		jobID, merged, err = l.manager.TrimPackages(id, m, priority)
 	default:
 		writeErrorString(ctx, fmt.Sprintf("Invalid action '%s' when modifying repo", action), http.StatusBadRequest)
 		return
@@ -101,7 +105,7 @@ func (l *Listener) ModifyRepo(ctx *fasthttp.RequestCtx) {
 		return
 	}
 	// Send back the ID of the created job
-	writeID(ctx, jobID)
+	writeID(ctx, jobID, merged)
 }
 
 // Check will compare a repo on disk with the DB
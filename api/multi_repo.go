@@ -50,20 +50,26 @@ func (l *Listener) CherryPickRepo(ctx *fasthttp.RequestCtx) {
 		writeErrorString(ctx, "Package name required when cherry-picking", http.StatusBadRequest)
 		return
 	}
+	// Get the optional priority
+	priority, err := readPriority(ctx)
+	if err != nil {
+		writeError(ctx, err, http.StatusBadRequest)
+		return
+	}
 	// Request the cherry pick
-	jobID, err := l.manager.CherryPick(left, right, pkg)
+	jobID, merged, err := l.manager.CherryPick(left, right, pkg, priority)
 	if err != nil {
 		writeError(ctx, err, http.StatusInternalServerError)
 		return
 	}
 	// write Job ID to the request
-	writeID(ctx, jobID)
+	writeID(ctx, jobID, merged)
 }
 
 // Clone will ask the backend to clone an existing repository into a new repository
 func (c *Client) Clone(src, dest string) (s *repo.Summary, j *jobs.Job, err error) {
 	// Create a new request
-	req, err := http.NewRequest("POST", formURI("api/v1/repos/"+src), nil)
+	req, err := http.NewRequest("POST", formURI("api/v1/repos/"+dest), nil)
 	if err != nil {
 		return
 	}
@@ -72,7 +78,10 @@ func (c *Client) Clone(src, dest string) (s *repo.Summary, j *jobs.Job, err erro
 	q.Add("clone", src)
 	req.URL.RawQuery = q.Encode()
 	// wait for job to complete
-	j, err = c.runJob(req)
+	if j, err = c.runJob(req); err != nil {
+		return
+	}
+	s, err = readSummary(j)
 	return
 }
 
@@ -93,14 +102,20 @@ func (l *Listener) CompareRepo(ctx *fasthttp.RequestCtx) {
 	// get the repo names
 	left := ctx.UserValue("left").(string)
 	right := ctx.UserValue("right").(string)
+	// Get the optional priority
+	priority, err := readPriority(ctx)
+	if err != nil {
+		writeError(ctx, err, http.StatusBadRequest)
+		return
+	}
 	// Request the comparison
-	jobID, err := l.manager.Compare(left, right)
+	jobID, merged, err := l.manager.Compare(left, right, priority)
 	if err != nil {
 		writeError(ctx, err, http.StatusInternalServerError)
 		return
 	}
 	// write Job ID to the request
-	writeID(ctx, jobID)
+	writeID(ctx, jobID, merged)
 }
 
 // Sync will ask the backend to sync one repo to another
@@ -120,12 +135,18 @@ func (l *Listener) SyncRepo(ctx *fasthttp.RequestCtx) {
 	// Get the repo names
 	left := ctx.UserValue("left").(string)
 	right := ctx.UserValue("right").(string)
+	// Get the optional priority
+	priority, err := readPriority(ctx)
+	if err != nil {
+		writeError(ctx, err, http.StatusBadRequest)
+		return
+	}
 	// Request a Sync
-	jobID, err := l.manager.Sync(left, right)
+	jobID, merged, err := l.manager.Sync(left, right, priority)
 	if err != nil {
 		writeError(ctx, err, http.StatusInternalServerError)
 		return
 	}
 	// write Job ID to the request
-	writeID(ctx, jobID)
+	writeID(ctx, jobID, merged)
 }
@@ -37,6 +37,7 @@ type Listener struct {
 	srv    *fasthttp.Server
 	router *router.Router
 	socket net.Listener
+	done   chan struct{}
 	// If systemd is enabled, we'll talk to it.
 	SystemdEnabled bool
 	// When we first started up.
@@ -54,6 +55,7 @@ func NewListener(store *jobs.Store, mgr *manager.Manager) (api *Listener, err er
 			Handler: r.Handler,
 		},
 		router:         r,
+		done:           make(chan struct{}),
 		SystemdEnabled: false,
 		timeStarted:    time.Now().UTC(),
 		store:          store,
@@ -63,23 +65,27 @@ func NewListener(store *jobs.Store, mgr *manager.Manager) (api *Listener, err er
 	// Set up the API bits
 	// Daemon Management
 	r.GET("/api/v1/status", api.Status)
-	r.PATCH("/api/v1/daemon", api.ModifyDaemon) // useless for now
+	r.PATCH("/api/v1/daemon", api.ModifyDaemon) // ?action={workers}&count={n}
+	r.GET("/api/v1/events", api.Events)         // ?job={id}
 
 	// Repo management
-	r.GET("/api/v1/repos", api.Repos)             // Summaries of all repos
-	r.POST("/api/v1/repos/:left", api.CreateRepo) // Clone, Create, Import
-	// r.GET("/api/v1/repos/:left", api.GetRepo) // Summary of repo
-	r.PATCH("/api/v1/repos/:left", api.ModifyRepo) // ?action={check, delta, index, rescan, trim-packages, trim-obsoletes}
-	r.DELETE("/api/v1/repos/:left", api.RemoveRepo)
+	r.GET("/api/v1/repos", api.Repos)              // Summaries of all repos
+	r.POST("/api/v1/repos/{left}", api.CreateRepo) // Clone, Create, Import
+	// r.GET("/api/v1/repos/{left}", api.GetRepo) // Summary of repo
+	r.PATCH("/api/v1/repos/{left}", api.ModifyRepo) // ?action={check, delta, index, rescan, trim-packages, trim-obsoletes}
+	r.DELETE("/api/v1/repos/{left}", api.RemoveRepo)
 
-	r.PATCH("/api/v1/repos/:left/cherrypick/:right", api.CherryPickRepo)
-	r.GET("/api/v1/repos/:left/compare/:right", api.CompareRepo)
-	r.PATCH("/api/v1/repos/:left/sync/:right", api.SyncRepo)
+	r.PATCH("/api/v1/repos/{left}/cherrypick/{right}", api.CherryPickRepo)
+	r.GET("/api/v1/repos/{left}/compare/{right}", api.CompareRepo)
+	r.PATCH("/api/v1/repos/{left}/sync/{right}", api.SyncRepo)
 
 	// Job Management
+	r.GET("/api/v1/jobs", api.Jobs)         // ?status=&type=&repo=&since=&until=&limit=&cursor=
 	r.DELETE("/api/v1/jobs", api.ResetJobs) // ?status={completed,failed,queued}
-	r.GET("/api/v1/jobs/:id", api.GetJob)
-	//r.DELETE("/api/v1/jobs/:id", api.CancelJob)
+	r.GET("/api/v1/jobs/{id}", api.GetJob)
+	r.PATCH("/api/v1/jobs/{id}", api.ModifyJob) // ?action={retry}
+	r.DELETE("/api/v1/jobs/{id}", api.CancelJob)
+	r.GET("/api/v1/jobs/{id}/log", api.GetJobLog) // ?offset={bytes}
 
 	return api, nil
 }
@@ -144,6 +150,8 @@ func (api *Listener) Start() error {
 
 // Close will shut down and cleanup the socket
 func (api *Listener) Close() {
+	// End any event streams, which would otherwise hold up the shutdown
+	close(api.done)
 	api.srv.Shutdown()
 
 	// We don't technically fully own it if systemd created it
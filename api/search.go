@@ -0,0 +1,143 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/getsolus/ferryd/jobs"
+	"github.com/olekukonko/tablewriter"
+	"github.com/valyala/fasthttp"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// JobsResponse is a page of results from the 'jobs' endpoint
+type JobsResponse struct {
+	// Jobs are the matching Jobs, newest first
+	Jobs jobs.List `json:"jobs"`
+	// Next is the cursor for the following page, or 0 if this is the last page
+	Next int `json:"next"`
+}
+
+// Print writes out a JobsResponse as a table
+func (r JobsResponse) Print(out io.Writer) {
+	if len(r.Jobs) == 0 {
+		fmt.Fprintln(out, "No matching jobs")
+		return
+	}
+	// Setup for writing as a table
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{
+		"ID",
+		"Status",
+		"Created",
+		"Run Time",
+		"Description",
+		"Message",
+	})
+	table.SetBorder(false)
+	for _, j := range r.Jobs {
+		run := ""
+		if j.Finished.Valid {
+			run = j.RunTime().String()
+		}
+		table.Append([]string{
+			strconv.Itoa(j.ID),
+			j.Status.String(),
+			j.Created.Time.Local().Format(time.RFC3339),
+			run,
+			j.Describe(),
+			j.Message.String,
+		})
+	}
+	table.Render()
+}
+
+// Jobs searches for a page of Jobs matching a Filter
+func (c *Client) Jobs(f jobs.Filter) (r JobsResponse, err error) {
+	// Create the request
+	req, err := http.NewRequest("GET", formURI("api/v1/jobs"), nil)
+	if err != nil {
+		return
+	}
+	// Set the query parameters
+	q := req.URL.Query()
+	if f.HasStatus {
+		q.Add("status", f.Status.String())
+	}
+	if f.Type != jobs.Invalid {
+		q.Add("type", f.Type.String())
+	}
+	if len(f.Repo) > 0 {
+		q.Add("repo", f.Repo)
+	}
+	if !f.Since.IsZero() {
+		q.Add("since", f.Since.Format(time.RFC3339))
+	}
+	if !f.Until.IsZero() {
+		q.Add("until", f.Until.Format(time.RFC3339))
+	}
+	if f.Limit > 0 {
+		q.Add("limit", strconv.Itoa(f.Limit))
+	}
+	if f.Cursor > 0 {
+		q.Add("cursor", strconv.Itoa(f.Cursor))
+	}
+	req.URL.RawQuery = q.Encode()
+	// Send the request
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	// Check for failure
+	if resp.StatusCode != http.StatusOK {
+		err = readError(resp.Body)
+		return
+	}
+	// Decode the body as a JobsResponse
+	err = json.NewDecoder(resp.Body).Decode(&r)
+	return
+}
+
+// Jobs handles requests to search for Jobs
+func (l *Listener) Jobs(ctx *fasthttp.RequestCtx) {
+	// Get the search parameters
+	f, err := readFilter(ctx)
+	if err != nil {
+		writeError(ctx, err, http.StatusBadRequest)
+		return
+	}
+	// Run the search
+	var r JobsResponse
+	if r.Jobs, r.Next, err = l.store.Search(f); err != nil {
+		writeError(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	// Always send a list, even if it's empty
+	if r.Jobs == nil {
+		r.Jobs = jobs.List{}
+	}
+	// Encode the JobsResponse as JSON in the body
+	enc := json.NewEncoder(ctx)
+	if err = enc.Encode(&r); err != nil {
+		writeError(ctx, err, http.StatusInternalServerError)
+	}
+}
@@ -49,17 +49,23 @@ func (l *Listener) CreateRepo(ctx *fasthttp.RequestCtx) {
 	id := ctx.UserValue("left").(string)
 	imp := ctx.QueryArgs().GetBool("import")
 	instant := ctx.QueryArgs().GetBool("instant")
+	// Get the optional priority
+	priority, err := readPriority(ctx)
+	if err != nil {
+		writeError(ctx, err, http.StatusBadRequest)
+		return
+	}
 	// Request the repo creation
 	var jobID int
-	var err error
+	var merged bool
 	if imp {
-		jobID, err = l.manager.Import(id, instant)
+		jobID, merged, err = l.manager.Import(id, instant, priority)
 	} else {
 		src := string(ctx.QueryArgs().Peek("clone"))
 		if len(src) == 0 {
-			jobID, err = l.manager.Create(id, instant)
+			jobID, merged, err = l.manager.Create(id, instant, priority)
 		} else {
-			jobID, err = l.manager.Clone(id, src)
+			jobID, merged, err = l.manager.Clone(src, id, priority)
 		}
 	}
 	if err != nil {
@@ -67,7 +73,7 @@ func (l *Listener) CreateRepo(ctx *fasthttp.RequestCtx) {
 		return
 	}
 	// Write the ID for the client
-	writeID(ctx, jobID)
+	writeID(ctx, jobID, merged)
 }
 
 // Import will ask ferryd to import a repository from disk
@@ -107,12 +113,18 @@ func (c *Client) Remove(id string) (j *jobs.Job, err error) {
 func (l *Listener) RemoveRepo(ctx *fasthttp.RequestCtx) {
 	// Get the query parameters
 	id := ctx.UserValue("left").(string)
-	// Request the repo creation
-	jobID, err := l.manager.Remove(id)
+	// Get the optional priority
+	priority, err := readPriority(ctx)
+	if err != nil {
+		writeError(ctx, err, http.StatusBadRequest)
+		return
+	}
+	// Request the repo removal
+	jobID, merged, err := l.manager.Remove(id, priority)
 	if err != nil {
 		writeError(ctx, err, http.StatusInternalServerError)
 		return
 	}
 	// Write the ID for the client
-	writeID(ctx, jobID)
+	writeID(ctx, jobID, merged)
 }
@@ -21,11 +21,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/getsolus/ferryd/jobs"
+	"github.com/getsolus/ferryd/manager"
 	"github.com/olekukonko/tablewriter"
 	"github.com/valyala/fasthttp"
 	"io"
 	"net/http"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -36,12 +38,23 @@ type StatusResponse struct {
 	TimeStarted time.Time `json:"time_started"`
 	// Version is the version of the daemon
 	Version string `json:"version"`
-	// CurrentJobs is a list of running and queued jobs
+	// Workers is the number of jobs which may run at once
+	Workers int `json:"workers"`
+	// Mode is whether the queue is running, draining or paused
+	Mode jobs.Mode `json:"mode"`
+	// CurrentJobs is a list of the most recent running and queued jobs
 	Current jobs.List `json:"current"`
-	// FailedJobs is a list of failed jobs
+	// FailedJobs is a list of the most recent failed jobs
 	Failed jobs.List `json:"failed"`
-	// CompletedJobs is a list of completed jobs
+	// CompletedJobs is a list of the most recent completed jobs
 	Completed jobs.List `json:"completed"`
+	// Running, Queued, FailedCount and CompletedCount are the total number of jobs with each status
+	Running        int `json:"running"`
+	Queued         int `json:"queued"`
+	FailedCount    int `json:"failed_count"`
+	CompletedCount int `json:"completed_count"`
+	// Scheduled is a list of recurring jobs, soonest first
+	Scheduled []manager.Scheduled `json:"scheduled"`
 }
 
 // Uptime will determine the uptime of the daemon
@@ -49,10 +62,25 @@ func (s StatusResponse) Uptime() time.Duration {
 	return time.Now().UTC().Sub(s.TimeStarted)
 }
 
+// StatusLimit is the number of jobs of each status listed by Print
+const StatusLimit = 10
+
+// printMore lets the user know where to find the jobs which didn't fit in a table
+func printMore(out io.Writer, shown, total int, statuses ...string) {
+	if total <= shown {
+		return
+	}
+	cmds := make([]string, len(statuses))
+	for i, status := range statuses {
+		cmds[i] = fmt.Sprintf("'jobs --status=%s'", status)
+	}
+	fmt.Fprintf(out, "\nShowing %d of %d, use %s to see the rest\n", shown, total, strings.Join(cmds, " and "))
+}
+
 // Print out all the failed jobs
 func (s StatusResponse) printFailed(out io.Writer) {
 	// Print header
-	fmt.Fprintf(out, "Failed jobs: (%d tracked)\n\n", len(s.Failed))
+	fmt.Fprintf(out, "Failed jobs: (%d tracked)\n\n", s.FailedCount)
 	if len(s.Failed) == 0 {
 		return
 	}
@@ -68,9 +96,9 @@ func (s StatusResponse) printFailed(out io.Writer) {
 		"Error",
 	})
 	table.SetBorder(false)
-	// Print the 10 most recent failures
+	// Print the most recent failures
 	for i, j := range s.Failed {
-		if i >= 10 {
+		if i >= StatusLimit {
 			break
 		}
 		table.Append([]string{
@@ -82,12 +110,13 @@ func (s StatusResponse) printFailed(out io.Writer) {
 		})
 	}
 	table.Render()
+	printMore(out, len(s.Failed), s.FailedCount, "failed")
 }
 
 // Print out all the completed jobs
 func (s StatusResponse) printCompleted(out io.Writer) {
 	// Print header
-	fmt.Fprintf(out, "Completed jobs: (%d tracked)\n\n", len(s.Completed))
+	fmt.Fprintf(out, "Completed jobs: (%d tracked)\n\n", s.CompletedCount)
 	if len(s.Completed) == 0 {
 		return
 	}
@@ -98,20 +127,22 @@ func (s StatusResponse) printCompleted(out io.Writer) {
 	table.SetHeader([]string{
 		"Status",
 		"Completed",
+		"Queued",
 		"Run Time",
 		"Duration",
 		"Description",
 		"Message",
 	})
 	table.SetBorder(false)
-	// Print the 10 most recent completed jobs
+	// Print the most recent completed jobs
 	for i, j := range s.Completed {
-		if i >= 9 {
+		if i >= StatusLimit {
 			break
 		}
 		table.Append([]string{
 			"completed",
 			j.Finished.Time.Format(time.RFC3339),
+			j.QueuedTime().String(),
 			j.RunTime().String(),
 			j.TotalTime().String(),
 			j.Describe(),
@@ -119,12 +150,13 @@ func (s StatusResponse) printCompleted(out io.Writer) {
 		})
 	}
 	table.Render()
+	printMore(out, len(s.Completed), s.CompletedCount, "completed")
 }
 
 // Print out all the queued jobs
 func (s StatusResponse) printCurrent(out io.Writer) {
 	// Print the header
-	fmt.Fprintf(out, "Queued jobs: (%d tracked)\n\n", len(s.Current))
+	fmt.Fprintf(out, "Queued jobs: (%d tracked)\n\n", s.Running+s.Queued)
 	if len(s.Current) == 0 {
 		return
 	}
@@ -134,26 +166,33 @@ func (s StatusResponse) printCurrent(out io.Writer) {
 	table := tablewriter.NewWriter(out)
 	table.SetHeader([]string{
 		"Status",
+		"Priority",
 		"Queue Time",
 		"Run Time",
 		"Description",
 	})
 	table.SetBorder(false)
-	// Print the 10 most recently queued jobs
+	// Print the most recently queued jobs
 	for i, j := range s.Current {
-		if i >= 10 {
+		if i >= StatusLimit {
 			break
 		}
 		if j.Status == jobs.Running {
+			desc := j.DescribeProgress()
+			if len(desc) == 0 {
+				desc = j.Describe()
+			}
 			table.Append([]string{
 				"running",
+				j.Priority.String(),
 				j.QueuedTime().String(),
-				j.RunTime().String(),
-				j.Describe(),
+				j.RunningSince().String(),
+				desc,
 			})
 		} else {
 			table.Append([]string{
 				"queued",
+				j.Priority.String(),
 				j.QueuedSince().String(),
 				"",
 				j.Describe(),
@@ -161,19 +200,54 @@ func (s StatusResponse) printCurrent(out io.Writer) {
 		}
 	}
 	table.Render()
+	printMore(out, len(s.Current), s.Running+s.Queued, "running", "queued")
+}
+
+// Print out the recurring jobs
+func (s StatusResponse) printScheduled(out io.Writer) {
+	// Print the header
+	fmt.Fprintf(out, "Scheduled jobs: (%d tracked)\n\n", len(s.Scheduled))
+	if len(s.Scheduled) == 0 {
+		return
+	}
+	// Setup to print as a table
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{
+		"Next Run",
+		"Schedule",
+		"Description",
+	})
+	table.SetBorder(false)
+	// Print them all, already sorted from soonest to latest
+	for _, sched := range s.Scheduled {
+		next := "never"
+		if !sched.Next.IsZero() {
+			next = sched.Next.Format(time.RFC3339)
+		}
+		table.Append([]string{
+			next,
+			sched.When,
+			sched.Job.Describe(),
+		})
+	}
+	table.Render()
 }
 
 // Print writes out a StatusResponse
 func (s StatusResponse) Print(out io.Writer) {
 	// Print daemon statistics
 	fmt.Fprintf(out, " - Daemon uptime: %v\n", s.Uptime())
-	fmt.Fprintf(out, " - Daemon version: %v\n\n", s.Version)
+	fmt.Fprintf(out, " - Daemon version: %v\n", s.Version)
+	fmt.Fprintf(out, " - Workers: %d\n", s.Workers)
+	fmt.Fprintf(out, " - Queue: %s\n\n", s.Mode)
 	// Print jobs
 	s.printFailed(out)
 	println()
 	s.printCurrent(out)
 	println()
 	s.printCompleted(out)
+	println()
+	s.printScheduled(out)
 }
 
 // Status retrieves the status of the ferryd service
@@ -197,28 +271,31 @@ func (l *Listener) Status(ctx *fasthttp.RequestCtx) {
 	ret := StatusResponse{
 		TimeStarted: l.timeStarted,
 		Version:     Version,
+		Workers:     l.manager.Workers(),
+		Mode:        l.store.Mode(),
 	}
-	// Add the active jobs
-	aj, err := l.store.Active()
-	if err != nil {
+	// Add the most recent jobs of each status, running jobs first
+	var running, queued jobs.List
+	var err error
+	if running, ret.Running, err = l.recentJobs(jobs.Running, StatusLimit); err != nil {
 		writeError(ctx, err, http.StatusInternalServerError)
 		return
 	}
-	ret.Current = aj
-	// Add the failed jobs
-	fj, err := l.store.Failed()
-	if err != nil {
+	if queued, ret.Queued, err = l.recentJobs(jobs.New, StatusLimit-len(running)); err != nil {
 		writeError(ctx, err, http.StatusInternalServerError)
 		return
 	}
-	ret.Failed = fj
-	// Add the completed jobs
-	cj, err := l.store.Completed()
-	if err != nil {
+	ret.Current = append(running, queued...)
+	if ret.Failed, ret.FailedCount, err = l.recentJobs(jobs.Failed, StatusLimit); err != nil {
 		writeError(ctx, err, http.StatusInternalServerError)
 		return
 	}
-	ret.Completed = cj
+	if ret.Completed, ret.CompletedCount, err = l.recentJobs(jobs.Completed, StatusLimit); err != nil {
+		writeError(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	// Add the recurring jobs
+	ret.Scheduled = l.manager.Scheduled()
 	// Encode the StatusResponse as JSON in the body
 	buf := bytes.Buffer{}
 	if err := json.NewEncoder(&buf).Encode(&ret); err != nil {
@@ -227,3 +304,19 @@ func (l *Listener) Status(ctx *fasthttp.RequestCtx) {
 	}
 	ctx.SetBody(buf.Bytes())
 }
+
+// recentJobs gets up to limit of the newest jobs with a status, and how many there are in total
+func (l *Listener) recentJobs(status jobs.JobStatus, limit int) (list jobs.List, count int, err error) {
+	f := jobs.Filter{
+		Status:    status,
+		HasStatus: true,
+		Limit:     limit,
+	}
+	if limit > 0 {
+		if list, _, err = l.store.Search(f); err != nil {
+			return
+		}
+	}
+	count, err = l.store.Count(f)
+	return
+}
@@ -20,12 +20,14 @@ import (
 	"errors"
 	"fmt"
 	log "github.com/DataDrake/waterlog"
+	"github.com/getsolus/ferryd/jobs"
 	"github.com/valyala/fasthttp"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"runtime"
 	"strconv"
+	"time"
 )
 
 // getMethodOrigin helps us determine the caller so that we can print
@@ -62,12 +64,16 @@ func writeError(ctx *fasthttp.RequestCtx, err error, code int) {
 	writeErrorString(ctx, err.Error(), code)
 }
 
-func readID(resp *http.Response) (id int, err error) {
+// MergedHeader is set to "true" when a new Job was merged with an identical queued Job
+const MergedHeader = "X-Job-Merged"
+
+func readID(resp *http.Response) (id int, merged bool, err error) {
 	// Check for failure
 	if resp.StatusCode != http.StatusOK {
 		err = readError(resp.Body)
 		return
 	}
+	merged = resp.Header.Get(MergedHeader) == "true"
 	// Read body as ID
 	raw, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -77,7 +83,70 @@ func readID(resp *http.Response) (id int, err error) {
 	return
 }
 
-func writeID(ctx *fasthttp.RequestCtx, id int) {
+// readPriority gets the optional "priority" query parameter for a new Job
+func readPriority(ctx *fasthttp.RequestCtx) (jobs.JobPriority, error) {
+	name := string(ctx.QueryArgs().Peek("priority"))
+	if len(name) == 0 {
+		return jobs.Default, nil
+	}
+	return jobs.ParsePriority(name)
+}
+
+// ParseTime reads a time as RFC3339, a local date like "2020-12-31", or a duration before now like "168h"
+func ParseTime(value string) (t time.Time, err error) {
+	if t, err = time.Parse(time.RFC3339, value); err == nil {
+		return
+	}
+	if t, err = time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return t, fmt.Errorf("invalid time '%s', must be RFC3339, a date, or a duration before now", value)
+	}
+	return time.Now().Add(-d), nil
+}
+
+// readFilter gets the query parameters for searching Jobs
+func readFilter(ctx *fasthttp.RequestCtx) (f jobs.Filter, err error) {
+	args := ctx.QueryArgs()
+	if v := string(args.Peek("status")); len(v) > 0 {
+		if f.Status, err = jobs.ParseStatus(v); err != nil {
+			return
+		}
+		f.HasStatus = true
+	}
+	if v := string(args.Peek("type")); len(v) > 0 {
+		if f.Type, err = jobs.ParseType(v); err != nil {
+			return
+		}
+	}
+	f.Repo = string(args.Peek("repo"))
+	if v := string(args.Peek("since")); len(v) > 0 {
+		if f.Since, err = ParseTime(v); err != nil {
+			return
+		}
+	}
+	if v := string(args.Peek("until")); len(v) > 0 {
+		if f.Until, err = ParseTime(v); err != nil {
+			return
+		}
+	}
+	if v := string(args.Peek("limit")); len(v) > 0 {
+		if f.Limit, err = strconv.Atoi(v); err != nil {
+			return
+		}
+	}
+	if v := string(args.Peek("cursor")); len(v) > 0 {
+		f.Cursor, err = strconv.Atoi(v)
+	}
+	return
+}
+
+func writeID(ctx *fasthttp.RequestCtx, id int, merged bool) {
+	if merged {
+		ctx.Response.Header.Set(MergedHeader, "true")
+	}
 	s := strconv.Itoa(id)
 	ctx.SetBodyString(s)
 	return
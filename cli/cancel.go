@@ -0,0 +1,68 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cli
+
+import (
+	"fmt"
+	"github.com/DataDrake/cli-ng/cmd"
+	"github.com/getsolus/ferryd/api"
+	"github.com/getsolus/ferryd/jobs"
+	"os"
+)
+
+// Cancel fulfills the "cancel" sub-command
+var Cancel = &cmd.CMD{
+	Name:  "cancel",
+	Alias: "cj",
+	Short: "Cancel a queued or running job",
+	Args:  &CancelArgs{},
+	Run:   CancelRun,
+}
+
+// CancelArgs are the arguments to the "cancel" sub-command
+type CancelArgs struct {
+	ID int64 `desc:"ID of the job to cancel"`
+}
+
+// CancelRun executes the "cancel" sub-command
+func CancelRun(r *cmd.RootCMD, c *cmd.CMD) {
+	// Convert our flags
+	flags := r.Flags.(*GlobalFlags)
+	args := c.Args.(*CancelArgs)
+	// Create a Client
+	client := v1.NewClient(flags.Socket)
+	defer client.Close()
+	// Send the request
+	j, err := client.CancelJob(int(args.ID))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error while cancelling job: %v\n", err)
+		os.Exit(1)
+	}
+	// Report when the job was stopped
+	switch {
+	case j.Status != jobs.Cancelled:
+		fmt.Printf("Job %d finished before it could be cancelled\n", j.ID)
+	case j.Started.Valid:
+		fmt.Printf("Job %d was cancelled during execution\n", j.ID)
+	case j.Attempts > 0:
+		fmt.Printf("Job %d was cancelled while waiting to be retried, after %d attempts\n", j.ID, j.Attempts)
+	default:
+		fmt.Printf("Job %d was cancelled before execution\n", j.ID)
+	}
+	// Print the job summary
+	j.Print()
+}
@@ -0,0 +1,53 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cli
+
+import (
+	"fmt"
+	"github.com/DataDrake/cli-ng/cmd"
+	"github.com/getsolus/ferryd/api"
+	"os"
+)
+
+// Drain fulfills the "drain" sub-command
+var Drain = &cmd.CMD{
+	Name:  "drain",
+	Alias: "dn",
+	Short: "Pause the queue and wait for running jobs to finish",
+	Args:  &DrainArgs{},
+	Run:   DrainRun,
+}
+
+// DrainArgs are the arguments to the "drain" sub-command
+type DrainArgs struct{}
+
+// DrainRun executes the "drain" sub-command
+func DrainRun(r *cmd.RootCMD, c *cmd.CMD) {
+	// Convert our flags
+	flags := r.Flags.(*GlobalFlags)
+	//args  := c.Args.(*DrainArgs)
+	// Create a Client
+	client := v1.NewClient(flags.Socket)
+	defer client.Close()
+	// Send the request
+	if err := client.Drain(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error while draining the queue: %v\n", err)
+		os.Exit(1)
+	}
+	// Report finished
+	fmt.Println("Successfully drained the queue, no jobs are running")
+}
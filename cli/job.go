@@ -0,0 +1,76 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cli
+
+import (
+	"fmt"
+	"github.com/DataDrake/cli-ng/cmd"
+	"github.com/getsolus/ferryd/api"
+	"os"
+)
+
+// Job fulfills the "job" sub-command
+var Job = &cmd.CMD{
+	Name:  "job",
+	Alias: "j",
+	Short: "Act on a single job, i.e. \"log\" to print its log",
+	Flags: &JobFlags{},
+	Args:  &JobArgs{},
+	Run:   JobRun,
+}
+
+// JobFlags are the flags for the "job" sub-command
+type JobFlags struct {
+	Follow bool `short:"f" long:"follow" desc:"Keep printing the log as it is written, until the job finishes"`
+}
+
+// JobArgs are the arguments to the "job" sub-command
+type JobArgs struct {
+	Action string `desc:"What to do with the job, only \"log\" for now"`
+	ID     int64  `desc:"ID of the job"`
+}
+
+// JobRun executes the "job" sub-command
+func JobRun(r *cmd.RootCMD, c *cmd.CMD) {
+	// Convert our flags
+	flags := r.Flags.(*GlobalFlags)
+	sub := c.Flags.(*JobFlags)
+	args := c.Args.(*JobArgs)
+	// Create a Client
+	client := v1.NewClient(flags.Socket)
+	defer client.Close()
+	// Pivot by action
+	var err error
+	switch args.Action {
+	case "log":
+		if sub.Follow {
+			err = client.FollowJobLog(int(args.ID), os.Stdout)
+			break
+		}
+		var data []byte
+		if data, err = client.GetJobLog(int(args.ID), 0); err == nil {
+			_, err = os.Stdout.Write(data)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid action '%s', only 'log' is supported\n", args.Action)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error while reading job log: %v\n", err)
+		os.Exit(1)
+	}
+}
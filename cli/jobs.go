@@ -0,0 +1,167 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/DataDrake/cli-ng/cmd"
+	"github.com/getsolus/ferryd/api"
+	"github.com/getsolus/ferryd/config"
+	"github.com/getsolus/ferryd/jobs"
+	"os"
+	"sort"
+)
+
+// Jobs fulfills the "jobs" sub-command
+var Jobs = &cmd.CMD{
+	Name:  "jobs",
+	Alias: "lj",
+	Short: "Search for jobs by status, type, repo and time, or \"export\" them as JSON lines",
+	Flags: &JobsFlags{},
+	Args:  &JobsArgs{},
+	Run:   JobsRun,
+}
+
+// JobsFlags are the flags for the "jobs" sub-command
+type JobsFlags struct {
+	Status string `short:"S" long:"status" desc:"Only jobs which are queued, running, failed, cancelled or completed"`
+	Type   string `short:"t" long:"type" desc:"Only jobs of this type, i.e. transit-package"`
+	Repo   string `short:"r" long:"repo" desc:"Only jobs using this repo as the source or destination"`
+	Since  string `short:"f" long:"since" desc:"Only jobs created since a RFC3339 time, a date, or a duration ago like 168h"`
+	Until  string `short:"u" long:"until" desc:"Only jobs created before a RFC3339 time, a date, or a duration ago like 24h"`
+	Limit  int64  `short:"n" long:"limit" desc:"Number of jobs in each page"`
+	Cursor int64  `short:"c" long:"cursor" desc:"Start from the page after this cursor"`
+	All    bool   `short:"a" long:"all" desc:"Print every page instead of just the first"`
+}
+
+// JobsArgs are the arguments to the "jobs" sub-command
+type JobsArgs struct {
+	Action []string `desc:"Optionally \"export\" to print every matching job as JSON lines, oldest first"`
+}
+
+// JobsRun executes the "jobs" sub-command
+func JobsRun(r *cmd.RootCMD, c *cmd.CMD) {
+	// Convert our flags
+	flags := r.Flags.(*GlobalFlags)
+	sub := c.Flags.(*JobsFlags)
+	args := c.Args.(*JobsArgs)
+	// Build the search
+	f, err := jobsFilter(sub)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in search: %v\n", err)
+		os.Exit(1)
+	}
+	// Create a Client
+	client := v1.NewClient(flags.Socket)
+	defer client.Close()
+	// Pivot by action
+	switch {
+	case len(args.Action) == 0:
+	case args.Action[0] == "export":
+		jobsExport(client, f)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid action '%s', only 'export' is supported\n", args.Action[0])
+		os.Exit(1)
+	}
+	for {
+		// Request a page
+		resp, err := client.Jobs(f)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error while searching jobs: %v\n", err)
+			os.Exit(1)
+		}
+		// Print the page
+		resp.Print(os.Stdout)
+		if resp.Next == 0 {
+			return
+		}
+		if !sub.All {
+			fmt.Printf("\nMore jobs available, use '--cursor=%d' to see the next page\n", resp.Next)
+			return
+		}
+		f.Cursor = resp.Next
+	}
+}
+
+// jobsFilter converts the flags for the "jobs" sub-command to a Filter
+func jobsFilter(sub *JobsFlags) (f jobs.Filter, err error) {
+	if len(sub.Status) > 0 {
+		if f.Status, err = jobs.ParseStatus(sub.Status); err != nil {
+			return
+		}
+		f.HasStatus = true
+	}
+	if len(sub.Type) > 0 {
+		if f.Type, err = jobs.ParseType(sub.Type); err != nil {
+			return
+		}
+	}
+	f.Repo = sub.Repo
+	if len(sub.Since) > 0 {
+		if f.Since, err = v1.ParseTime(sub.Since); err != nil {
+			return
+		}
+	}
+	if len(sub.Until) > 0 {
+		if f.Until, err = v1.ParseTime(sub.Until); err != nil {
+			return
+		}
+	}
+	f.Limit = int(sub.Limit)
+	f.Cursor = int(sub.Cursor)
+	return
+}
+
+// jobsExport prints every Job matching a Filter as JSON lines, oldest first, including
+// any which were already pruned into the Job archives
+func jobsExport(client *v1.Client, f jobs.Filter) {
+	// Start with the Jobs which were pruned from the DB
+	list, err := jobs.ReadArchives(config.Current.JobArchivePath(), f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: pruned jobs are missing from the export: %v\n", err)
+	}
+	// Collect every page, which come newest first
+	f.Limit = jobs.MaxLimit
+	for {
+		resp, err := client.Jobs(f)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error while exporting jobs: %v\n", err)
+			os.Exit(1)
+		}
+		list = append(list, resp.Jobs...)
+		if resp.Next == 0 {
+			break
+		}
+		f.Cursor = resp.Next
+	}
+	// Print them in the order they happened, once each
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	enc := json.NewEncoder(os.Stdout)
+	for i, j := range list {
+		if i > 0 && j.ID == list[i-1].ID {
+			continue
+		}
+		if err := enc.Encode(j); err != nil {
+			fmt.Fprintf(os.Stderr, "Error while exporting jobs: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cli
+
+import (
+	"fmt"
+	"github.com/DataDrake/cli-ng/cmd"
+	"github.com/getsolus/ferryd/api"
+	"os"
+)
+
+// Pause fulfills the "pause" sub-command
+var Pause = &cmd.CMD{
+	Name:  "pause",
+	Alias: "pa",
+	Short: "Stop starting new jobs, and hold incoming transit manifests",
+	Args:  &PauseArgs{},
+	Run:   PauseRun,
+}
+
+// PauseArgs are the arguments to the "pause" sub-command
+type PauseArgs struct{}
+
+// PauseRun executes the "pause" sub-command
+func PauseRun(r *cmd.RootCMD, c *cmd.CMD) {
+	// Convert our flags
+	flags := r.Flags.(*GlobalFlags)
+	//args  := c.Args.(*PauseArgs)
+	// Create a Client
+	client := v1.NewClient(flags.Socket)
+	defer client.Close()
+	// Send the request
+	if err := client.Pause(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error while pausing the queue: %v\n", err)
+		os.Exit(1)
+	}
+	// Report finished
+	fmt.Println("Successfully paused the queue, running jobs will still finish")
+}
@@ -0,0 +1,53 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cli
+
+import (
+	"fmt"
+	"github.com/DataDrake/cli-ng/cmd"
+	"github.com/getsolus/ferryd/api"
+	"os"
+)
+
+// Resume fulfills the "resume" sub-command
+var Resume = &cmd.CMD{
+	Name:  "resume",
+	Alias: "re",
+	Short: "Start running jobs again, including any held transit manifests",
+	Args:  &ResumeArgs{},
+	Run:   ResumeRun,
+}
+
+// ResumeArgs are the arguments to the "resume" sub-command
+type ResumeArgs struct{}
+
+// ResumeRun executes the "resume" sub-command
+func ResumeRun(r *cmd.RootCMD, c *cmd.CMD) {
+	// Convert our flags
+	flags := r.Flags.(*GlobalFlags)
+	//args  := c.Args.(*ResumeArgs)
+	// Create a Client
+	client := v1.NewClient(flags.Socket)
+	defer client.Close()
+	// Send the request
+	if err := client.Resume(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error while resuming the queue: %v\n", err)
+		os.Exit(1)
+	}
+	// Report finished
+	fmt.Println("Successfully resumed the queue")
+}
@@ -0,0 +1,56 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cli
+
+import (
+	"fmt"
+	"github.com/DataDrake/cli-ng/cmd"
+	"github.com/getsolus/ferryd/api"
+	"os"
+)
+
+// Retry fulfills the "retry" sub-command
+var Retry = &cmd.CMD{
+	Name:  "retry",
+	Alias: "rt",
+	Short: "Queue a failed job to run again",
+	Args:  &RetryArgs{},
+	Run:   RetryRun,
+}
+
+// RetryArgs are the arguments to the "retry" sub-command
+type RetryArgs struct {
+	ID int64 `desc:"ID of the failed job"`
+}
+
+// RetryRun executes the "retry" sub-command
+func RetryRun(r *cmd.RootCMD, c *cmd.CMD) {
+	// Convert our flags
+	flags := r.Flags.(*GlobalFlags)
+	args := c.Args.(*RetryArgs)
+	// Create a Client
+	client := v1.NewClient(flags.Socket)
+	defer client.Close()
+	// Run the job
+	j, err := client.Retry(int(args.ID))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error while retrying job: %v\n", err)
+		os.Exit(1)
+	}
+	// Print the job summary
+	j.Print()
+}
@@ -40,12 +40,21 @@ func init() {
 	Root.RegisterCMD(Version)
 	// API
 	Root.RegisterCMD(Status)
+	Root.RegisterCMD(Watch)
 	// Daemon
 	Root.RegisterCMD(Daemon)
+	Root.RegisterCMD(Drain)
+	Root.RegisterCMD(Pause)
+	Root.RegisterCMD(Resume)
+	Root.RegisterCMD(Workers)
 	// Job Management
+	Root.RegisterCMD(Cancel)
+	Root.RegisterCMD(Job)
+	Root.RegisterCMD(Jobs)
 	Root.RegisterCMD(ResetCompleted)
 	Root.RegisterCMD(ResetFailed)
 	Root.RegisterCMD(ResetQueue)
+	Root.RegisterCMD(Retry)
 	// Single-Repo
 	Root.RegisterCMD(Check)
 	Root.RegisterCMD(Create)
@@ -0,0 +1,67 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cli
+
+import (
+	"fmt"
+	"github.com/DataDrake/cli-ng/cmd"
+	"github.com/getsolus/ferryd/api"
+	"io"
+	"os"
+)
+
+// Watch fulfills the "watch" sub-command
+var Watch = &cmd.CMD{
+	Name:  "watch",
+	Alias: "w",
+	Short: "Print job and repo events from the daemon as they happen",
+	Args:  &WatchArgs{},
+	Run:   WatchRun,
+}
+
+// WatchArgs are the arguments to the "watch" sub-command
+type WatchArgs struct{}
+
+// WatchRun executes the "watch" sub-command
+func WatchRun(r *cmd.RootCMD, c *cmd.CMD) {
+	// Convert our flags
+	flags := r.Flags.(*GlobalFlags)
+	//args  := c.Args.(*WatchArgs)
+	// Create a Client
+	client := v1.NewClient(flags.Socket)
+	defer client.Close()
+	// Open the event stream
+	s, err := client.Events(0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error while opening event stream: %v\n", err)
+		os.Exit(1)
+	}
+	defer s.Close()
+	// Print events until the daemon stops sending them
+	for {
+		ev, err := s.Next()
+		if err == io.EOF {
+			fmt.Println("Event stream closed by the daemon")
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error while reading events: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("[%s] %s\n", ev.Time.Local().Format("15:04:05"), ev)
+	}
+}
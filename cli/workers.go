@@ -0,0 +1,55 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cli
+
+import (
+	"fmt"
+	"github.com/DataDrake/cli-ng/cmd"
+	"github.com/getsolus/ferryd/api"
+	"os"
+)
+
+// Workers fulfills the "workers" sub-command
+var Workers = &cmd.CMD{
+	Name:  "workers",
+	Alias: "wk",
+	Short: "Change the number of jobs the daemon runs at once",
+	Args:  &WorkersArgs{},
+	Run:   WorkersRun,
+}
+
+// WorkersArgs are the arguments to the "workers" sub-command
+type WorkersArgs struct {
+	Count int `desc:"Number of jobs to run at once"`
+}
+
+// WorkersRun executes the "workers" sub-command
+func WorkersRun(r *cmd.RootCMD, c *cmd.CMD) {
+	// Convert our flags
+	flags := r.Flags.(*GlobalFlags)
+	args := c.Args.(*WorkersArgs)
+	// Create a Client
+	client := v1.NewClient(flags.Socket)
+	defer client.Close()
+	// Send the request
+	if err := client.SetWorkers(args.Count); err != nil {
+		fmt.Fprintf(os.Stderr, "Error while setting workers: %v\n", err)
+		os.Exit(1)
+	}
+	// Report finished
+	fmt.Printf("Successfully set workers to %d, running jobs will finish first\n", args.Count)
+}
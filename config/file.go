@@ -28,6 +28,10 @@ const (
 	AssetSuffix = "assets"
 	// DeltaSuffix for delta creation
 	DeltaSuffix = "deltas"
+	// JobArchiveSuffix for the history of pruned Jobs
+	JobArchiveSuffix = "job-archive"
+	// JobLogSuffix for the logs of each Job
+	JobLogSuffix = "job-logs"
 	// RepoSuffix for repo storage
 	RepoSuffix = "repos"
 	// TransitSuffix for incoming packages
@@ -55,6 +59,48 @@ type File struct {
 	LockFile string
 	// Socket for the Daemon
 	Socket string
+	// Schedules for recurring maintenance Jobs
+	Schedules []Schedule
+	// Retention for the history of finished Jobs
+	Retention Retention
+	// Coalesce turns merging of identical queued Jobs on or off, by job type, i.e. "index" or "trim-packages"
+	Coalesce map[string]bool
+	// Workers is the number of Jobs which may run at once, or 0 for half the number of CPUs
+	Workers int
+	// Limits on the number of running Jobs of each class
+	Limits Limits
+}
+
+// Retention sets how long finished Jobs are kept before being archived and removed from the Job DB
+type Retention struct {
+	// Completed is the number of days to keep completed Jobs, or 0 to keep them forever
+	Completed int
+	// Failed is the number of days to keep failed Jobs, or 0 to keep them forever
+	Failed int
+	// Cancelled is the number of days to keep cancelled Jobs, or 0 to keep them forever
+	Cancelled int
+	// Archives is the number of archive files to keep, or 0 to keep them all
+	Archives int
+}
+
+// Limits caps the number of Jobs of each class which may run at once, so that heavy Jobs can't take every worker
+type Limits struct {
+	// Heavy is the most Check, Delta, Import and Rescan Jobs to run at once, or 0 for no limit besides Workers
+	Heavy int
+	// Light is the most of every other kind of Job to run at once, or 0 for no limit besides Workers
+	Light int
+}
+
+// Schedule describes a Job which the Daemon should run periodically, i.e. a nightly Delta
+type Schedule struct {
+	// When is a cron expression, i.e. "0 2 * * *" or "@weekly"
+	When string
+	// Action is the name of the job, as used by ferryctl, i.e. "delta" or "trim-packages"
+	Action string
+	// Repo to run the Job on
+	Repo string
+	// Max releases to keep, only for "trim-packages"
+	Max int
 }
 
 // Current is the configuration of the system as it was when the daemon started
@@ -97,12 +143,6 @@ func Load() error {
 	return nil
 }
 
-func init() {
-	if err := Load(); err != nil {
-		panic(err.Error())
-	}
-}
-
 // AssetPath for index generation and obsoletion
 func (f *File) AssetPath() string {
 	return filepath.Join(f.BaseDir, AssetSuffix)
@@ -113,6 +153,16 @@ func (f *File) DeltaPath() string {
 	return filepath.Join(f.BuildDir, DeltaSuffix)
 }
 
+// JobArchivePath for the history of pruned Jobs
+func (f *File) JobArchivePath() string {
+	return filepath.Join(f.BaseDir, JobArchiveSuffix)
+}
+
+// JobLogPath for the logs of each Job
+func (f *File) JobLogPath() string {
+	return filepath.Join(f.BaseDir, JobLogSuffix)
+}
+
 // RepoPath for repo storage
 func (f *File) RepoPath() string {
 	return filepath.Join(f.BaseDir, RepoSuffix)
@@ -0,0 +1,225 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"github.com/getsolus/libeopkg/archive"
+	"github.com/ulikunitz/xz"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// installTarball is the name of the compressed file contents within an eopkg
+	installTarball = "install.tar.xz"
+	// deltaTarball is the name of the reduced tarball used to build a delta
+	deltaTarball = "delta-install.tar.xz"
+)
+
+// changedFiles finds every file in the new package which does not exist, or is different, in the old package
+func changedFiles(left, right *archive.Archive) (changed map[string]bool, removed int) {
+	old := make(map[string]*archive.File)
+	for _, f := range left.Files.File {
+		old[f.Path] = f
+	}
+	changed = make(map[string]bool)
+	for _, f := range right.Files.File {
+		if prev, ok := old[f.Path]; !ok || !prev.Equal(f) {
+			changed[f.Path] = true
+		}
+		delete(old, f.Path)
+	}
+	// Whatever is left over was removed in the new package
+	removed = len(old)
+	return
+}
+
+// copyChanged writes every changed entry of the install tarball into the delta tarball
+func copyChanged(in io.Reader, out io.Writer, changed map[string]bool) error {
+	src := tar.NewReader(in)
+	dst := tar.NewWriter(out)
+	for {
+		header, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		// Ensure that we compare things in the same way
+		if !changed[strings.TrimSuffix(header.Name, "/")] {
+			continue
+		}
+		if err = dst.WriteHeader(header); err != nil {
+			return err
+		}
+		if header.Typeflag == tar.TypeReg || header.Typeflag == tar.TypeRegA {
+			if _, err = io.Copy(dst, src); err != nil {
+				return err
+			}
+		}
+	}
+	return dst.Close()
+}
+
+// reduceTarball decompresses the install tarball of a package and recompresses only the changed entries
+func reduceTarball(pkg *archive.Archive, dstPath string, changed map[string]bool) error {
+	f := pkg.FindFile(installTarball)
+	if f == nil {
+		return fmt.Errorf("package is missing '%s'", installTarball)
+	}
+	in, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	src, err := xz.NewReader(in)
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	dst, err := xz.NewWriter(out)
+	if err != nil {
+		return err
+	}
+	if err = copyChanged(src, dst, changed); err != nil {
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+// writeDelta creates a new eopkg, copying everything but the install tarball from the new package
+func writeDelta(newPackage, xzPath, targetPath string) (err error) {
+	src, err := zip.OpenReader(newPackage)
+	if err != nil {
+		return
+	}
+	defer src.Close()
+	out, err := os.Create(targetPath)
+	if err != nil {
+		return
+	}
+	defer out.Close()
+	dst := zip.NewWriter(out)
+	// Copy the metadata, file list and scripts
+	for _, f := range src.File {
+		if strings.HasPrefix(f.Name, "install.tar") {
+			continue
+		}
+		if err = copyZipEntry(dst, f); err != nil {
+			return
+		}
+	}
+	// Add the reduced tarball
+	st, err := os.Stat(xzPath)
+	if err != nil {
+		return
+	}
+	fh, err := zip.FileInfoHeader(st)
+	if err != nil {
+		return
+	}
+	fh.Name = installTarball
+	w, err := dst.CreateHeader(fh)
+	if err != nil {
+		return
+	}
+	tarball, err := os.Open(xzPath)
+	if err != nil {
+		return
+	}
+	defer tarball.Close()
+	if _, err = io.Copy(w, tarball); err != nil {
+		return
+	}
+	if err = dst.Close(); err != nil {
+		return
+	}
+	return out.Sync()
+}
+
+// copyZipEntry duplicates a single file from one zip archive into another
+func copyZipEntry(dst *zip.Writer, f *zip.File) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	header := f.FileHeader
+	w, err := dst.CreateHeader(&header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// ProduceDelta creates a delta package at "targetPath" which contains only the files of
+// "newPackage" that differ from "oldPackage". The work is carried out in "tmpDir".
+//
+// archive.ErrMismatchedDelta is returned if the two packages are unrelated, and
+// archive.ErrDeltaPointless if they contain exactly the same files.
+func ProduceDelta(tmpDir, oldPackage, newPackage, targetPath string) error {
+	// Read in both packages
+	left, err := archive.OpenAll(oldPackage)
+	if err != nil {
+		return err
+	}
+	defer left.Close()
+	right, err := archive.OpenAll(newPackage)
+	if err != nil {
+		return err
+	}
+	defer right.Close()
+	if !left.IsDeltaPossible(right) {
+		return archive.ErrMismatchedDelta
+	}
+	// Work out which files need to be included
+	changed, removed := changedFiles(left, right)
+	if len(changed) == 0 && removed == 0 {
+		return archive.ErrDeltaPointless
+	}
+	// Build the reduced tarball
+	workDir, err := ioutil.TempDir(tmpDir, filepath.Base(targetPath)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+	deltaPath := filepath.Join(workDir, deltaTarball)
+	if err = reduceTarball(right, deltaPath, changed); err != nil {
+		return err
+	}
+	// Write out the delta package
+	if err = writeDelta(newPackage, deltaPath, targetPath); err != nil {
+		os.Remove(targetPath)
+		return err
+	}
+	return nil
+}
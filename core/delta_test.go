@@ -0,0 +1,131 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package core
+
+import (
+	"archive/tar"
+	"github.com/getsolus/libeopkg/archive"
+	"github.com/ulikunitz/xz"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	oldTestPackage = "testdata/hello-1.0-1-1-x86_64.eopkg"
+	newTestPackage = "testdata/hello-1.1-2-1-x86_64.eopkg"
+)
+
+func TestChangedFiles(t *testing.T) {
+	left := &archive.Archive{
+		Files: &archive.Files{
+			File: []*archive.File{
+				{Path: "usr/bin/nano", Hash: "aaaa"},
+				{Path: "usr/share/doc/nano", Hash: "bbbb"},
+				{Path: "usr/share/nano/old", Hash: "cccc"},
+			},
+		},
+	}
+	right := &archive.Archive{
+		Files: &archive.Files{
+			File: []*archive.File{
+				{Path: "usr/bin/nano", Hash: "dddd"},
+				{Path: "usr/share/doc/nano", Hash: "bbbb"},
+				{Path: "usr/share/nano/new", Hash: "eeee"},
+			},
+		},
+	}
+	changed, removed := changedFiles(left, right)
+	if len(changed) != 2 || !changed["usr/bin/nano"] || !changed["usr/share/nano/new"] {
+		t.Fatalf("Invalid changed files: %v", changed)
+	}
+	if removed != 1 {
+		t.Fatalf("Expected 1 removed file, found: %d", removed)
+	}
+	changed, removed = changedFiles(left, left)
+	if len(changed) != 0 || removed != 0 {
+		t.Fatalf("Identical packages should have no changes, found: %v, %d", changed, removed)
+	}
+}
+
+func TestProduceDelta(t *testing.T) {
+	tmp := t.TempDir()
+	target := filepath.Join(tmp, "hello-1-2-1-x86_64.delta.eopkg")
+	if err := ProduceDelta(tmp, oldTestPackage, newTestPackage, target); err != nil {
+		t.Fatalf("Failed to produce delta, reason: %s", err)
+	}
+	delta, err := archive.OpenAll(target)
+	if err != nil {
+		t.Fatalf("Failed to open delta, reason: %s", err)
+	}
+	defer delta.Close()
+	if delta.Meta.Package.GetRelease() != 2 {
+		t.Fatalf("Delta should have the metadata of release 2, found: %d", delta.Meta.Package.GetRelease())
+	}
+	// Only the changed and added files should be in the tarball
+	f := delta.FindFile(installTarball)
+	if f == nil {
+		t.Fatalf("Delta is missing '%s'", installTarball)
+	}
+	in, err := f.Open()
+	if err != nil {
+		t.Fatalf("Failed to open tarball, reason: %s", err)
+	}
+	defer in.Close()
+	xr, err := xz.NewReader(in)
+	if err != nil {
+		t.Fatalf("Failed to decompress tarball, reason: %s", err)
+	}
+	expected := map[string]string{
+		"usr/bin/hello":       "#!/bin/sh\necho hello, world\n",
+		"usr/share/hello/new": "New in 1.1\n",
+	}
+	tr := tar.NewReader(xr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read tarball, reason: %s", err)
+		}
+		want, ok := expected[header.Name]
+		if !ok {
+			t.Fatalf("Unexpected file in delta: %s", header.Name)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("Failed to read '%s', reason: %s", header.Name, err)
+		}
+		if string(data) != want {
+			t.Fatalf("Expected '%s' to contain %q, found: %q", header.Name, want, data)
+		}
+		delete(expected, header.Name)
+	}
+	if len(expected) != 0 {
+		t.Fatalf("Files missing from delta: %v", expected)
+	}
+}
+
+func TestProduceDeltaMismatched(t *testing.T) {
+	tmp := t.TempDir()
+	target := filepath.Join(tmp, "hello-2-1-1-x86_64.delta.eopkg")
+	if err := ProduceDelta(tmp, newTestPackage, oldTestPackage, target); err != archive.ErrMismatchedDelta {
+		t.Fatalf("Expected a mismatched delta, found: %v", err)
+	}
+}
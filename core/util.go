@@ -21,7 +21,6 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"github.com/getsolus/ferryd/util"
-	"github.com/getsolus/libeopkg/archive"
 	"hash"
 	"io"
 	"io/ioutil"
@@ -112,22 +111,3 @@ func WriteSHA256Sum(inpPath, outPath string) error {
 	}
 	return ioutil.WriteFile(outPath, []byte(hash), 00644)
 }
-
-// ProduceDelta will attempt to batch the delta production between the
-// two listed file paths and then copy it into the final targetPath
-func ProduceDelta(tmpDir, oldPackage, newPackage, targetPath string) error {
-	del, err := archive.NewDeltaProducer(tmpDir, oldPackage, newPackage)
-	if err != nil {
-		return err
-	}
-	defer del.Close()
-	path, err := del.Create()
-	if err != nil {
-		return err
-	}
-
-	// Always nuke the tmpfile
-	defer os.Remove(path)
-
-	return LinkOrCopyFile(path, targetPath, false)
-}
@@ -0,0 +1,185 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// descriptors are shorthands for common schedules
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field describes the allowed values for one part of a Spec
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Spec is a parsed cron expression, with one bit set for each allowed value of a field
+type Spec struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar are set when the day of month or day of week is unrestricted
+	domStar, dowStar bool
+}
+
+// Parse reads a standard five field cron expression, i.e. "30 2 * * 1-5", or one of the "@daily" shorthands
+func Parse(expr string) (s *Spec, err error) {
+	expr = strings.TrimSpace(expr)
+	if d, ok := descriptors[expr]; ok {
+		expr = d
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("cron expression '%s' must have %d fields", expr, len(fields))
+	}
+	bits := make([]uint64, len(fields))
+	for i, part := range parts {
+		if bits[i], err = parseField(part, fields[i]); err != nil {
+			return nil, err
+		}
+	}
+	s = &Spec{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: parts[2] == "*",
+		dowStar: parts[4] == "*",
+	}
+	// Sunday may be written as 0 or 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return
+}
+
+// parseField converts a comma separated list of values, ranges and steps to a bit set
+func parseField(part string, f field) (bits uint64, err error) {
+	for _, item := range strings.Split(part, ",") {
+		// Split off the step, if any
+		step := 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step '%s' in %s", item[i+1:], f.name)
+			}
+			item = item[:i]
+		}
+		// Work out the range
+		start, end := f.min, f.max
+		switch {
+		case item == "*":
+		case strings.Contains(item, "-"):
+			bounds := strings.SplitN(item, "-", 2)
+			if start, err = parseValue(bounds[0], f); err != nil {
+				return
+			}
+			if end, err = parseValue(bounds[1], f); err != nil {
+				return
+			}
+			if start > end {
+				return 0, fmt.Errorf("invalid range '%s' in %s", item, f.name)
+			}
+		default:
+			if start, err = parseValue(item, f); err != nil {
+				return
+			}
+			end = start
+			// "5/15" means every 15 starting at 5
+			if step > 1 {
+				end = f.max
+			}
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return
+}
+
+// parseValue converts a single number, making sure it is in range
+func parseValue(value string, f field) (v int, err error) {
+	if v, err = strconv.Atoi(value); err != nil {
+		return 0, fmt.Errorf("invalid value '%s' in %s", value, f.name)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s must be between %d and %d, found: %d", f.name, f.min, f.max, v)
+	}
+	return
+}
+
+// has checks if a value is allowed by a bit set
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+// matchDay checks if a day is allowed, using either field when both are restricted like cron does
+func (s *Spec) matchDay(t time.Time) bool {
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next finds the first time after "t" which matches this Spec, or the zero Time if there is none
+func (s *Spec) Next(t time.Time) time.Time {
+	// Start from the next whole minute
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// Any valid schedule repeats within a few years, so don't search forever
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !has(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !has(s.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !has(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,68 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+var nextTests = []struct {
+	expr, from, next string
+}{
+	{"@daily", "2020-12-31T11:05:00Z", "2021-01-01T00:00:00Z"},
+	{"0 2 * * *", "2020-12-31T01:59:30Z", "2020-12-31T02:00:00Z"},
+	{"0 2 * * *", "2020-12-31T02:00:00Z", "2021-01-01T02:00:00Z"},
+	{"30 3 * * 0", "2020-12-31T11:05:00Z", "2021-01-03T03:30:00Z"},
+	{"30 3 * * 7", "2020-12-31T11:05:00Z", "2021-01-03T03:30:00Z"},
+	{"*/15 * * * *", "2020-12-31T11:05:00Z", "2020-12-31T11:15:00Z"},
+	{"5/20 9-17 * * 1-5", "2021-01-01T17:50:00Z", "2021-01-04T09:05:00Z"},
+	{"0 0 29 2 *", "2021-01-01T00:00:00Z", "2024-02-29T00:00:00Z"},
+	{"0 0 13 * 5", "2021-01-01T00:00:00Z", "2021-01-08T00:00:00Z"},
+}
+
+func TestNext(t *testing.T) {
+	for _, test := range nextTests {
+		s, err := Parse(test.expr)
+		if err != nil {
+			t.Fatalf("Failed to parse '%s': %v", test.expr, err)
+		}
+		from, _ := time.Parse(time.RFC3339, test.from)
+		next := s.Next(from).Format(time.RFC3339)
+		if next != test.next {
+			t.Errorf("Next for '%s' after %s should be %s, found: %s", test.expr, test.from, test.next, next)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Expected an error parsing '%s'", expr)
+		}
+	}
+}
+
+func TestNextNever(t *testing.T) {
+	s, err := Parse("0 0 31 2 *")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if next := s.Next(time.Now()); !next.IsZero() {
+		t.Fatalf("Expected no next time, found: %s", next)
+	}
+}
@@ -13,5 +13,6 @@ require (
 	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/olekukonko/tablewriter v0.0.4
 	github.com/radu-munteanu/fsnotify v1.4.3-0.20190225091322-6b752d1de779
+	github.com/ulikunitz/xz v0.5.15
 	github.com/valyala/fasthttp v1.18.0
 )
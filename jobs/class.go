@@ -0,0 +1,84 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package jobs
+
+import (
+	"github.com/getsolus/ferryd/config"
+)
+
+// JobClass groups JobTypes by how much work they do, so that each group can be limited separately
+type JobClass int
+
+const (
+	// Light Jobs mostly work with the DB and links, like Index or TransitPackage
+	Light JobClass = 0
+	// Heavy Jobs read or write every package in a repo, like Delta or Check
+	Heavy JobClass = 1
+)
+
+// String gets the name of a JobClass
+func (c JobClass) String() string {
+	if c == Heavy {
+		return "heavy"
+	}
+	return "light"
+}
+
+// Class gets the JobClass of a JobType
+func (t JobType) Class() JobClass {
+	switch t {
+	case Check, Delta, Import, Rescan:
+		return Heavy
+	default:
+		return Light
+	}
+}
+
+// classLimits keeps track of the number of running Jobs in each JobClass, and the most allowed
+type classLimits struct {
+	max     map[JobClass]int
+	running map[JobClass]int
+}
+
+// newClassLimits sets the most Jobs of each JobClass to run at once, where 0 is no limit
+func newClassLimits(l config.Limits) classLimits {
+	return classLimits{
+		max: map[JobClass]int{
+			Light: l.Light,
+			Heavy: l.Heavy,
+		},
+		running: make(map[JobClass]int),
+	}
+}
+
+// available checks if another Job of the same JobClass may run
+func (c classLimits) available(j *Job) bool {
+	class := j.Type.Class()
+	return c.max[class] <= 0 || c.running[class] < c.max[class]
+}
+
+// acquire counts a Job as running
+func (c classLimits) acquire(j *Job) {
+	c.running[j.Type.Class()]++
+}
+
+// release counts a Job as no longer running
+func (c classLimits) release(j *Job) {
+	if class := j.Type.Class(); c.running[class] > 0 {
+		c.running[class]--
+	}
+}
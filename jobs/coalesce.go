@@ -0,0 +1,49 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package jobs
+
+import (
+	"fmt"
+)
+
+// defaultCoalesce are the JobTypes which are merged with an identical queued Job by default,
+// because running them twice in a row gives the same result as running them once
+var defaultCoalesce = map[JobType]bool{
+	Check:         true,
+	Compare:       true,
+	Delta:         true,
+	Index:         true,
+	Rescan:        true,
+	TrimObsoletes: true,
+	TrimPackages:  true,
+}
+
+// coalescing works out which JobTypes are merged, from the defaults and any overrides by type name
+func coalescing(overrides map[string]bool) (map[JobType]bool, error) {
+	coalesce := make(map[JobType]bool)
+	for t, on := range defaultCoalesce {
+		coalesce[t] = on
+	}
+	for name, on := range overrides {
+		t, err := ParseType(name)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read the coalesce config, reason: '%s'", err.Error())
+		}
+		coalesce[t] = on
+	}
+	return coalesce, nil
+}
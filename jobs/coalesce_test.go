@@ -0,0 +1,119 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+var coalesceTests = []struct {
+	name           string
+	queued, pushed Job
+	// retrying puts the queued Job back in the queue after a failed attempt
+	retrying bool
+	merged   bool
+	// priority of the queued Job afterwards
+	priority JobPriority
+}{
+	{
+		name:     "identical",
+		queued:   Job{Type: Index, Src: "unstable", Priority: Normal},
+		pushed:   Job{Type: Index, Src: "unstable", Priority: Normal},
+		merged:   true,
+		priority: Normal,
+	},
+	{
+		name:     "raises the priority",
+		queued:   Job{Type: Index, Src: "unstable", Priority: Low},
+		pushed:   Job{Type: Index, Src: "unstable", Priority: High},
+		merged:   true,
+		priority: High,
+	},
+	{
+		name:     "never lowers the priority",
+		queued:   Job{Type: Index, Src: "unstable", Priority: High},
+		pushed:   Job{Type: Index, Src: "unstable", Priority: Low},
+		merged:   true,
+		priority: High,
+	},
+	{
+		name:     "different repo",
+		queued:   Job{Type: Index, Src: "unstable", Priority: Normal},
+		pushed:   Job{Type: Index, Src: "stable", Priority: Normal},
+		priority: Normal,
+	},
+	{
+		name:     "different arguments",
+		queued:   Job{Type: TrimPackages, Src: "unstable", Max: 3, Priority: Low},
+		pushed:   Job{Type: TrimPackages, Src: "unstable", Max: 2, Priority: Low},
+		priority: Low,
+	},
+	{
+		name:     "type which doesn't coalesce",
+		queued:   Job{Type: Sync, Src: "unstable", Dst: "stable", Priority: Normal},
+		pushed:   Job{Type: Sync, Src: "unstable", Dst: "stable", Priority: Normal},
+		priority: Normal,
+	},
+	{
+		name:     "waiting to be retried",
+		queued:   Job{Type: Index, Src: "unstable", Priority: Low},
+		pushed:   Job{Type: Index, Src: "unstable", Priority: High},
+		retrying: true,
+		priority: Low,
+	},
+}
+
+func TestCoalesce(t *testing.T) {
+	for _, test := range coalesceTests {
+		s := testStore(t)
+		queued := test.queued
+		id := mustPush(t, s, &queued)
+		if test.retrying {
+			j, _, err := s.Claim()
+			if err != nil {
+				t.Fatalf("%s: failed to claim job %d: %v", test.name, id, err)
+			}
+			j.Status = New
+			j.NotBefore.Time = time.Now().UTC().Add(time.Hour)
+			j.NotBefore.Valid = true
+			if err = s.Retire(j); err != nil {
+				t.Fatalf("%s: failed to retire job %d: %v", test.name, id, err)
+			}
+		}
+		pushed := test.pushed
+		pid, merged, err := s.Push(&pushed)
+		if err != nil {
+			t.Fatalf("%s: failed to push job: %v", test.name, err)
+		}
+		switch {
+		case merged != test.merged:
+			t.Errorf("%s: expected merged to be %t, found %t", test.name, test.merged, merged)
+		case merged && pid != id:
+			t.Errorf("%s: expected to merge with job %d, found job %d", test.name, id, pid)
+		case !merged && pid == id:
+			t.Errorf("%s: expected a new job, found job %d", test.name, pid)
+		}
+		j, err := s.GetJob(id)
+		if err != nil {
+			t.Fatalf("%s: failed to get job %d: %v", test.name, id, err)
+		}
+		if j.Priority != test.priority {
+			t.Errorf("%s: expected job %d to be %s priority, found %s", test.name, id, test.priority, j.Priority)
+		}
+	}
+}
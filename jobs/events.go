@@ -0,0 +1,167 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package jobs
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventBuffer is the number of Events a subscriber may fall behind before it is dropped
+const EventBuffer = 256
+
+// EventKind is the type of change reported by an Event
+type EventKind string
+
+const (
+	// JobChanged is sent when a Job is queued or changes status
+	JobChanged EventKind = "job"
+	// JobProgress is sent when a running Job reports its progress
+	JobProgress EventKind = "progress"
+	// JobLogged is sent when a running Job writes a line to its log
+	JobLogged EventKind = "log"
+	// RepoChanged is sent when a Job has finished modifying a repo
+	RepoChanged EventKind = "repo"
+)
+
+// Event is a notification of a change to a Job or repo
+type Event struct {
+	Kind  EventKind `json:"kind"`
+	Time  time.Time `json:"time"`
+	JobID int       `json:"job_id,omitempty"`
+	Job   *Job      `json:"job,omitempty"`
+	Repo  string    `json:"repo,omitempty"`
+	Line  string    `json:"line,omitempty"`
+}
+
+// String summarizes an Event for printing, i.e. "Job 12 running: Delta unstable: 1,204/8,930 packages (13%)"
+func (ev Event) String() string {
+	switch {
+	case ev.Kind == RepoChanged:
+		return fmt.Sprintf("Repo '%s' changed", ev.Repo)
+	case ev.Kind == JobLogged:
+		return fmt.Sprintf("Job %d: %s", ev.JobID, strings.TrimRight(ev.Line, "\n"))
+	case ev.Job == nil:
+		return string(ev.Kind)
+	case ev.Job.Status <= Running && len(ev.Job.Summary) > 0:
+		return fmt.Sprintf("Job %d %s: %s", ev.Job.ID, ev.Job.Status, ev.Job.Summary)
+	case ev.Job.Message.Valid && len(ev.Job.Message.String) > 0:
+		// Finished jobs are better described by how they ended
+		return fmt.Sprintf("Job %d %s: %s", ev.Job.ID, ev.Job.Status, ev.Job.Message.String)
+	default:
+		return fmt.Sprintf("Job %d %s: %s", ev.Job.ID, ev.Job.Status, ev.Job.Describe())
+	}
+}
+
+// Events sends each Event to every subscriber
+type Events struct {
+	sync.Mutex
+	subs map[chan Event]bool
+}
+
+// NewEvents creates an Events with no subscribers
+func NewEvents() *Events {
+	return &Events{
+		subs: make(map[chan Event]bool),
+	}
+}
+
+// Subscribe gets a channel which receives every Event from now on. The channel is closed
+// when unsubscribed, or if the subscriber falls more than EventBuffer events behind.
+func (e *Events) Subscribe() chan Event {
+	ch := make(chan Event, EventBuffer)
+	e.Lock()
+	e.subs[ch] = true
+	e.Unlock()
+	return ch
+}
+
+// Unsubscribe stops sending Events to a channel and closes it
+func (e *Events) Unsubscribe(ch chan Event) {
+	e.Lock()
+	if e.subs[ch] {
+		delete(e.subs, ch)
+		close(ch)
+	}
+	e.Unlock()
+}
+
+// publish sends an Event to every subscriber, without waiting on slow ones
+func (e *Events) publish(ev Event) {
+	e.Lock()
+	for ch := range e.subs {
+		select {
+		case ch <- ev:
+		default:
+			// Missing events would leave the subscriber with the wrong picture
+			delete(e.subs, ch)
+			close(ch)
+		}
+	}
+	e.Unlock()
+}
+
+// jobChanged reports a new status for a Job
+func (e *Events) jobChanged(j *Job) {
+	e.publish(Event{
+		Kind:  JobChanged,
+		Time:  time.Now().UTC(),
+		JobID: j.ID,
+		Job:   j.snapshot(),
+	})
+}
+
+// jobProgress reports the progress of a running Job
+func (e *Events) jobProgress(j *Job) {
+	e.publish(Event{
+		Kind:  JobProgress,
+		Time:  time.Now().UTC(),
+		JobID: j.ID,
+		Job:   j.snapshot(),
+	})
+}
+
+// jobLogged reports a line written to the log of a running Job, without the rest of the
+// Job, since there may be many lines and subscribers only have room for so many events
+func (e *Events) jobLogged(j *Job, line string) {
+	e.publish(Event{
+		Kind:  JobLogged,
+		Time:  time.Now().UTC(),
+		JobID: j.ID,
+		Line:  line,
+	})
+}
+
+// repoChanged reports that a repo has been modified
+func (e *Events) repoChanged(name string) {
+	e.publish(Event{
+		Kind: RepoChanged,
+		Time: time.Now().UTC(),
+		Repo: name,
+	})
+}
+
+// snapshot copies a Job so that it can be sent to subscribers while the original keeps changing
+func (j *Job) snapshot() *Job {
+	c := *j
+	c.progress = nil
+	c.log = nil
+	c.Summary = c.DescribeProgress()
+	return &c
+}
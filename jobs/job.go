@@ -31,6 +31,23 @@ type Job struct {
 	Dst string `db:"dst" json:"dst"`
 	Pkg string `db:"pkg" json:"pkg"`
 	Max int    `db:"max" json:"max"`
+	// Job scheduling
+	Priority    JobPriority `db:"priority" json:"priority"`
+	Attempts    int         `db:"attempts" json:"attempts"`
+	MaxAttempts int         `db:"max_attempts" json:"max_attempts"`
+	NotBefore   NullTime    `db:"not_before" json:"not_before"`
+	RetryOf     int         `db:"retry_of" json:"retry_of"`
+	// Job progress
+	Phase string `db:"phase" json:"phase"`
+	Done  int    `db:"done" json:"done"`
+	Total int    `db:"total" json:"total"`
+	Bytes int64  `db:"bytes" json:"bytes"`
+	// Summary is the rendered progress, only filled in for API responses
+	Summary  string `db:"-" json:"progress,omitempty"`
+	progress *Progress
+	log      *Log
+	// locks are the repos held while running
+	locks lockSet
 	// Job tracking
 	Created  NullTime   `db:"created" json:"created"`
 	Started  NullTime   `db:"started" json:"started"`
@@ -79,11 +96,11 @@ func (j *Job) Describe() string {
 	case Create:
 		return fmt.Sprintf("Creating new repo '%s'", j.Dst)
 	case Delta:
-		return fmt.Sprintf("Generating Deltas for repo '%s'", j.Dst)
+		return fmt.Sprintf("Generating Deltas for repo '%s'", j.Src)
 	case Import:
 		return fmt.Sprintf("Importing existing repo '%s'", j.Src)
 	case Index:
-		return fmt.Sprintf("Generating Index for repo '%s'", j.Dst)
+		return fmt.Sprintf("Generating Index for repo '%s'", j.Src)
 	case Remove:
 		return fmt.Sprintf("Removing repo '%s' from DB", j.Src)
 	case Rescan:
@@ -105,6 +122,13 @@ func (j *Job) Describe() string {
 func (j *Job) Print() {
 	fmt.Printf("ID:   %d\n", j.ID)
 	fmt.Printf("Type: %s\n", typeMap[j.Type])
+	fmt.Printf("Priority: %s\n", j.Priority)
+	if j.RetryOf != 0 {
+		fmt.Printf("Retry Of: %d\n", j.RetryOf)
+	}
+	if j.Attempts > 0 {
+		fmt.Printf("Attempts: %d of %d\n", j.Attempts, j.MaxAttempts)
+	}
 	fmt.Println("Arguments:")
 	none := true
 	if len(j.Src) > 0 {
@@ -134,6 +158,9 @@ func (j *Job) Print() {
 		fmt.Printf("\tStarted:  %s\n", j.Started.Time.Format(time.RFC3339))
 		fmt.Printf("\t\tQueued:   %s\n", j.QueuedTime().String())
 	}
+	if j.Waiting() {
+		fmt.Printf("\tRetrying: %s\n", j.NotBefore.Time.Format(time.RFC3339))
+	}
 	if j.Finished.Valid && !j.Finished.Time.IsZero() {
 		fmt.Printf("\tFinished: %s\n", j.Finished.Time.Format(time.RFC3339))
 		fmt.Printf("\t\tRuntime: %s\n", j.RunTime().String())
@@ -142,6 +169,12 @@ func (j *Job) Print() {
 		fmt.Printf("\tTotal:      %s\n", j.TotalTime().String())
 	}
 	fmt.Printf("Status: %s\n", statusMap[j.Status])
+	if p := j.DescribeProgress(); len(p) > 0 {
+		fmt.Printf("Progress: %s\n", p)
+		if j.Bytes > 0 {
+			fmt.Printf("Processed: %s\n", formatBytes(j.Bytes))
+		}
+	}
 	if j.Message.Valid && len(j.Message.String) > 0 {
 		fmt.Printf("Last Message: %s\n", j.Message.String)
 	}
@@ -0,0 +1,119 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package jobs
+
+// poolRepo is the name of the repo which every package is transited into, see repo.PoolName
+const poolRepo = "pool"
+
+// Locks works out which repos a Job reads from and which it modifies. Every Job which adds or
+// drops Archives, deltas or links also modifies the pool, since they are all kept there.
+func (j *Job) Locks() (read, write []string) {
+	switch j.Type {
+	case Check:
+		read = []string{j.Src}
+	case Compare:
+		read = []string{j.Src, j.Dst}
+	case Clone:
+		read = []string{j.Src}
+		write = []string{j.Dst}
+	case CherryPick, Sync:
+		read = []string{j.Src}
+		write = []string{j.Dst, poolRepo}
+	case Create:
+		write = []string{j.Dst}
+	case Index:
+		write = []string{j.Src}
+	case Delta, Import, Remove, Rescan, TrimObsoletes, TrimPackages:
+		write = []string{j.Src, poolRepo}
+	case TransitPackage:
+		// The manifest in Pkg only ever targets the pool directly, and the Store adds the
+		// instant-transit repos it is linked into, see SetInstantTransit
+		write = []string{poolRepo}
+	}
+	return
+}
+
+// lockSet is the repos a running Job reads from and modifies
+type lockSet struct {
+	read, write []string
+}
+
+// lockSet works out the repos used by a Job, including any instant-transit repos
+func (s *Store) lockSet(j *Job) (l lockSet) {
+	l.read, l.write = j.Locks()
+	if j.Type == TransitPackage {
+		l.write = append(l.write, s.instant...)
+	}
+	return
+}
+
+// SetInstantTransit sets the names of the repos which TransitPackage Jobs link new packages into
+func (s *Store) SetInstantTransit(names []string) {
+	s.Lock()
+	s.instant = names
+	// Transits may no longer be waiting on a removed repo
+	s.wake()
+	s.Unlock()
+}
+
+// repoLocks keeps track of the repos in use, with a count of readers or -1 for a writer
+type repoLocks map[string]int
+
+// available checks if a Job can use all of its repos without conflicting with other jobs
+func (l repoLocks) available(ls lockSet) bool {
+	for _, name := range ls.read {
+		if l[name] < 0 {
+			return false
+		}
+	}
+	for _, name := range ls.write {
+		if l[name] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// acquire marks the repos of a Job as in use
+func (l repoLocks) acquire(ls lockSet) {
+	for _, name := range ls.read {
+		if l[name] >= 0 {
+			l[name]++
+		}
+	}
+	for _, name := range ls.write {
+		l[name] = -1
+	}
+}
+
+// release marks the repos of a Job as no longer in use
+func (l repoLocks) release(ls lockSet) {
+	for _, name := range ls.read {
+		if l[name] > 0 {
+			l[name]--
+		}
+	}
+	for _, name := range ls.write {
+		l[name] = 0
+	}
+	// Don't keep track of unused repos
+	for name, count := range l {
+		if count == 0 {
+			delete(l, name)
+		}
+	}
+}
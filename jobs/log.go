@@ -0,0 +1,159 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package jobs
+
+import (
+	"fmt"
+	log "github.com/DataDrake/waterlog"
+	"github.com/getsolus/ferryd/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxLogSize is the largest a Job's log may grow, after which further lines are only sent to the daemon's log
+const MaxLogSize = 1 << 20
+
+// Log is a handle for a running Job to record what it is doing. Every line is also sent to the
+// daemon's log, so a nil Log, as for a Job which wasn't claimed from a Store, only does that.
+type Log struct {
+	sync.Mutex
+	job    *Job
+	f      *os.File
+	size   int64
+	events *Events
+}
+
+// LogPath gets the location of the log for a Job
+func LogPath(id int) string {
+	return filepath.Join(config.Current.JobLogPath(), fmt.Sprintf("%d.log", id))
+}
+
+// Log gets the handle for recording the output of this Job
+func (j *Job) Log() *Log {
+	return j.log
+}
+
+// openLog starts the log for a claimed Job, adding to the log of any earlier attempts
+func openLog(j *Job, events *Events) (l *Log, err error) {
+	if err = os.MkdirAll(config.Current.JobLogPath(), 0755); err != nil {
+		return
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	// IDs may be reused once the history is reset, so a first attempt always starts over
+	if j.Attempts <= 1 {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(LogPath(j.ID), flags, 0644)
+	if err != nil {
+		return
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return
+	}
+	l = &Log{
+		job:    j,
+		f:      f,
+		size:   info.Size(),
+		events: events,
+	}
+	return
+}
+
+// removeLogs deletes the logs of Jobs which are no longer in the DB
+func removeLogs(ids []int) {
+	for _, id := range ids {
+		if err := os.Remove(LogPath(id)); err != nil && !os.IsNotExist(err) {
+			log.Warnf("Failed to remove the log of job '%d', reason: '%s'\n", id, err.Error())
+		}
+	}
+}
+
+// Infof records a line of information
+func (l *Log) Infof(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	log.Infof("Job %s: %s", l.id(), msg)
+	l.write("INFO", msg)
+}
+
+// Warnf records a line about a problem which doesn't stop the Job
+func (l *Log) Warnf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	log.Warnf("Job %s: %s", l.id(), msg)
+	l.write("WARN", msg)
+}
+
+// Errorf records a line about a problem which stops the Job
+func (l *Log) Errorf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	log.Errorf("Job %s: %s", l.id(), msg)
+	l.write("ERROR", msg)
+}
+
+// id gets the ID of the Job for the daemon's log
+func (l *Log) id() string {
+	if l == nil {
+		return "-"
+	}
+	return fmt.Sprintf("'%d'", l.job.ID)
+}
+
+// write appends a line to the log file, until it is full
+func (l *Log) write(level, msg string) {
+	if l == nil {
+		return
+	}
+	l.Lock()
+	defer l.Unlock()
+	if l.f == nil || l.size >= MaxLogSize {
+		return
+	}
+	line := fmt.Sprintf("%s [%s] %s\n", time.Now().UTC().Format(time.RFC3339), level, strings.TrimRight(msg, "\n"))
+	if l.size+int64(len(line)) > MaxLogSize {
+		line = fmt.Sprintf("%s [WARN] Log is full, see the daemon's log for the rest\n", time.Now().UTC().Format(time.RFC3339))
+		// Always leave room for the warning
+		l.size = MaxLogSize
+	}
+	n, err := l.f.WriteString(line)
+	if err != nil {
+		log.Warnf("Failed to write to the log of job '%d', reason: '%s'\n", l.job.ID, err.Error())
+		return
+	}
+	if l.size < MaxLogSize {
+		l.size += int64(n)
+	}
+	l.events.jobLogged(l.job, line)
+}
+
+// close finishes the log file
+func (l *Log) close() {
+	if l == nil {
+		return
+	}
+	l.Lock()
+	if l.f != nil {
+		if err := l.f.Close(); err != nil {
+			log.Warnf("Failed to close the log of job '%d', reason: '%s'\n", l.job.ID, err.Error())
+		}
+		l.f = nil
+	}
+	l.Unlock()
+}
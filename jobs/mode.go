@@ -0,0 +1,118 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package jobs
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrResumed is returned when the queue is resumed while waiting for it to drain
+var ErrResumed = errors.New("Queue was resumed before running jobs finished")
+
+// Mode describes whether the queue is handing out Jobs
+type Mode string
+
+const (
+	// QueueRunning is the normal mode, where queued Jobs are claimed as soon as they can run
+	QueueRunning Mode = "running"
+	// QueueDraining is a paused queue, still waiting for the Jobs it was running to finish
+	QueueDraining Mode = "draining"
+	// QueuePaused is a queue where no more Jobs will be claimed until it is resumed, and none are running
+	QueuePaused Mode = "paused"
+)
+
+// Mode reports whether the queue is running, draining or paused
+func (s *Store) Mode() Mode {
+	s.Lock()
+	defer s.Unlock()
+	return s.mode()
+}
+
+// mode works out the current Mode, the Store must be locked
+func (s *Store) mode() Mode {
+	switch {
+	case !s.paused:
+		return QueueRunning
+	case len(s.running) > 0:
+		return QueueDraining
+	default:
+		return QueuePaused
+	}
+}
+
+// Paused checks if the queue is paused, along with a channel which is closed the next time it
+// is paused or resumed, so that anything held back while paused can wait to pick up again
+func (s *Store) Paused() (paused bool, changed <-chan struct{}) {
+	s.Lock()
+	defer s.Unlock()
+	return s.paused, s.modeChanged
+}
+
+// Pause stops any more Jobs from being claimed, while still accepting new ones into the queue
+func (s *Store) Pause() {
+	s.Lock()
+	if !s.paused {
+		s.paused = true
+		s.changeMode()
+	}
+	s.Unlock()
+}
+
+// Resume lets Jobs be claimed again after the queue was paused
+func (s *Store) Resume() {
+	s.Lock()
+	if s.paused {
+		s.paused = false
+		s.changeMode()
+		// Anything queued in the meantime is ready to go
+		s.wake()
+	}
+	s.Unlock()
+}
+
+// Drain pauses the queue and waits for the running Jobs to finish, or until "ctx" is done
+func (s *Store) Drain(ctx context.Context) error {
+	s.Lock()
+	if !s.paused {
+		s.paused = true
+		s.changeMode()
+	}
+	for len(s.running) > 0 {
+		if !s.paused {
+			s.Unlock()
+			return ErrResumed
+		}
+		// Retiring a job or resuming the queue will wake us
+		ready := s.ready
+		s.Unlock()
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		s.Lock()
+	}
+	s.Unlock()
+	return nil
+}
+
+// changeMode lets anything waiting on the Mode know that it has changed, the Store must be locked
+func (s *Store) changeMode() {
+	close(s.modeChanged)
+	s.modeChanged = make(chan struct{})
+}
@@ -18,6 +18,7 @@ package jobs
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"time"
 )
 
@@ -45,6 +46,16 @@ func (ns *NullString) UnmarshalText(text []byte) error {
 	return nil
 }
 
+// Scan reads a NullString from the DB
+func (ns *NullString) Scan(value interface{}) error {
+	return (*sql.NullString)(ns).Scan(value)
+}
+
+// Value converts a NullString for storage in the DB
+func (ns NullString) Value() (driver.Value, error) {
+	return sql.NullString(ns).Value()
+}
+
 // NullTime extends sql.NullTime with JSON-compatible Marshall/Unmarshall
 type NullTime sql.NullTime
 
@@ -53,7 +64,8 @@ func (nt NullTime) MarshalText() ([]byte, error) {
 	if !nt.Valid {
 		return []byte("null"), nil
 	}
-	return []byte(nt.Time.UTC().Format("2006-01-02T15:04:05Z")), nil
+	// Keep milliseconds, so that short delays like time spent queued can be measured
+	return []byte(nt.Time.UTC().Format("2006-01-02T15:04:05.999Z")), nil
 }
 
 // UnmarshalText parses a NullTime from text and determines if it is a valid value
@@ -64,7 +76,8 @@ func (nt *NullTime) UnmarshalText(text []byte) error {
 	if v == "null" {
 		return nil
 	}
-	t, err := time.Parse("2006-01-02T15:04:05Z", v)
+	// Older Jobs were written without the fractional seconds
+	t, err := time.Parse("2006-01-02T15:04:05.999Z", v)
 	if err != nil {
 		return err
 	}
@@ -72,3 +85,13 @@ func (nt *NullTime) UnmarshalText(text []byte) error {
 	nt.Time = t
 	return nil
 }
+
+// Scan reads a NullTime from the DB
+func (nt *NullTime) Scan(value interface{}) error {
+	return (*sql.NullTime)(nt).Scan(value)
+}
+
+// Value converts a NullTime for storage in the DB
+func (nt NullTime) Value() (driver.Value, error) {
+	return sql.NullTime(nt).Value()
+}
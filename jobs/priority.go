@@ -0,0 +1,77 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package jobs
+
+import (
+	"fmt"
+)
+
+// JobPriority determines the order in which waiting Jobs are claimed, highest first
+type JobPriority int
+
+const (
+	// Default indicates that a Job should use the priority for its JobType
+	Default JobPriority = 0
+	// Low is used for long-running maintenance, like Deltas and Trims
+	Low JobPriority = 1
+	// Normal is used for most Jobs
+	Normal JobPriority = 2
+	// High is used for Jobs which developers are waiting on, like Transits
+	High JobPriority = 3
+)
+
+var priorityMap = map[JobPriority]string{
+	Default: "default",
+	Low:     "low",
+	Normal:  "normal",
+	High:    "high",
+}
+
+// String gets the name of a JobPriority
+func (p JobPriority) String() string {
+	if name, ok := priorityMap[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", int(p))
+}
+
+// ParsePriority converts the name of a JobPriority to its value
+func ParsePriority(name string) (JobPriority, error) {
+	for p, n := range priorityMap {
+		if n == name {
+			return p, nil
+		}
+	}
+	return Default, fmt.Errorf("invalid job priority '%s', must be one of: low, normal, high", name)
+}
+
+// defaultPriorities are the priorities for each JobType, when not otherwise specified
+var defaultPriorities = map[JobType]JobPriority{
+	Delta:          Low,
+	Index:          High,
+	TransitPackage: High,
+	TrimObsoletes:  Low,
+	TrimPackages:   Low,
+}
+
+// DefaultPriority gets the priority that a JobType uses by default
+func DefaultPriority(t JobType) JobPriority {
+	if p, ok := defaultPriorities[t]; ok {
+		return p
+	}
+	return Normal
+}
@@ -0,0 +1,132 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package jobs
+
+import (
+	"fmt"
+	log "github.com/DataDrake/waterlog"
+	"strconv"
+	"time"
+)
+
+// ProgressInterval is the shortest time between saving the progress of a running Job to the DB
+const ProgressInterval = time.Second
+
+// Progress is a handle for a running Job to report how far along it is
+type Progress struct {
+	job   *Job
+	save  func(j *Job) error
+	saved time.Time
+}
+
+// Progress gets the handle for reporting the progress of this Job, which is nil unless it was claimed from a Store
+func (j *Job) Progress() *Progress {
+	return j.progress
+}
+
+// Phase starts a new stage of work on "total" items, i.e. "packages", or 0 if the number is unknown
+func (p *Progress) Phase(name string, total int) {
+	if p == nil {
+		return
+	}
+	p.job.Phase = name
+	p.job.Done = 0
+	p.job.Total = total
+	p.job.Log().Infof("Started %s\n", name)
+	// Always show the start of a new phase
+	p.flush()
+}
+
+// Step records that one more item has been finished, along with the number of bytes it used
+func (p *Progress) Step(bytes int64) {
+	if p == nil {
+		return
+	}
+	p.job.Done++
+	p.job.Bytes += bytes
+	if time.Since(p.saved) >= ProgressInterval {
+		p.flush()
+	}
+}
+
+// flush saves the current progress to the DB
+func (p *Progress) flush() {
+	p.saved = time.Now()
+	if err := p.save(p.job); err != nil {
+		log.Warnf("Failed to save the progress of job '%d', reason: '%s'\n", p.job.ID, err.Error())
+	}
+}
+
+// target names the repos that a Job works on
+func (j *Job) target() string {
+	switch {
+	case len(j.Src) > 0 && len(j.Dst) > 0:
+		return j.Src + " to " + j.Dst
+	case len(j.Src) > 0:
+		return j.Src
+	default:
+		return j.Dst
+	}
+}
+
+// DescribeProgress summarizes the progress of a Job, i.e. "Delta unstable: 1,204/8,930 packages (13%)"
+func (j *Job) DescribeProgress() string {
+	if len(j.Phase) == 0 {
+		return ""
+	}
+	desc := fmt.Sprintf("%s %s: ", typeMap[j.Type], j.target())
+	switch {
+	case j.Total > 0:
+		// Counts which were only estimated may overshoot
+		percent := j.Done * 100 / j.Total
+		if percent > 100 {
+			percent = 100
+		}
+		desc += fmt.Sprintf("%s/%s %s (%d%%)", commas(int64(j.Done)), commas(int64(j.Total)), j.Phase, percent)
+	case j.Done > 0:
+		desc += fmt.Sprintf("%s %s", commas(int64(j.Done)), j.Phase)
+	default:
+		desc += j.Phase
+	}
+	return desc
+}
+
+// commas formats a number with thousands separators, i.e. "8,930"
+func commas(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	if n < 0 {
+		return "-" + commas(-n)
+	}
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// formatBytes converts a number of bytes to a human-readable size, i.e. "1.5 GiB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -19,21 +19,57 @@ package jobs
 // JobSchema is the SQLite3 schema for the Jobs table
 const JobSchema = `
 CREATE TABLE IF NOT EXISTS jobs (
-    id       INTEGER PRIMARY KEY,
-    type     INTEGER,
-    src      STRING,
-    dst      STRING,
-    pkg      STRING,
-    max      INTEGER,
-    created  DATETIME,
-    started  DATETIME,
-    finished DATETIME,
-    status   INTEGER,
-    message  TEXT,
-    results  BLOB
+    id           INTEGER PRIMARY KEY,
+    type         INTEGER,
+    src          STRING,
+    dst          STRING,
+    pkg          STRING,
+    max          INTEGER,
+    priority     INTEGER DEFAULT 2,
+    attempts     INTEGER DEFAULT 0,
+    max_attempts INTEGER DEFAULT 1,
+    not_before   DATETIME,
+    retry_of     INTEGER DEFAULT 0,
+    phase        STRING DEFAULT '',
+    done         INTEGER DEFAULT 0,
+    total        INTEGER DEFAULT 0,
+    bytes        INTEGER DEFAULT 0,
+    created      DATETIME,
+    started      DATETIME,
+    finished     DATETIME,
+    status       INTEGER,
+    message      TEXT,
+    results      BLOB
 )
 `
 
+// jobColumns are the columns added to the Jobs table since it was first created, for upgrading existing DBs
+var jobColumns = []struct {
+	Name, Def string
+}{
+	{"priority", "INTEGER DEFAULT 2"},
+	{"attempts", "INTEGER DEFAULT 0"},
+	{"max_attempts", "INTEGER DEFAULT 1"},
+	{"not_before", "DATETIME"},
+	{"retry_of", "INTEGER DEFAULT 0"},
+	{"phase", "STRING DEFAULT ''"},
+	{"done", "INTEGER DEFAULT 0"},
+	{"total", "INTEGER DEFAULT 0"},
+	{"bytes", "INTEGER DEFAULT 0"},
+}
+
+// JobIndexes are the SQLite3 indexes for searching the Jobs table, newest first
+const JobIndexes = `
+CREATE INDEX IF NOT EXISTS jobs_status  ON jobs (status, id);
+CREATE INDEX IF NOT EXISTS jobs_type    ON jobs (type, id);
+CREATE INDEX IF NOT EXISTS jobs_src     ON jobs (src, id);
+CREATE INDEX IF NOT EXISTS jobs_dst     ON jobs (dst, id);
+CREATE INDEX IF NOT EXISTS jobs_created ON jobs (created);
+`
+
+// listColumns is a query for the names of the existing columns of the Jobs table
+const listColumns = "SELECT name FROM pragma_table_info('jobs')"
+
 // Queries for retrieving Jobs of a particular status
 const (
 	newJobs       = "SELECT * FROM jobs WHERE status=0"
@@ -43,15 +79,26 @@ const (
 	completedJobs = "SELECT * FROM jobs WHERE status=4"
 )
 
+// Queries for searching Jobs, with the conditions from a Filter in between
+const (
+	searchJobs  = "SELECT * FROM jobs"
+	searchOrder = " ORDER BY id DESC LIMIT ?"
+	countJobs   = "SELECT COUNT(*) FROM jobs"
+)
+
 // Insert is a query for creating a new Job
 const Insert = `
 INSERT INTO jobs (
     id, type,
-    src, dst, pkg, max,
+    src, dst, pkg, max, priority,
+    attempts, max_attempts, not_before, retry_of,
+    phase, done, total, bytes,
     created, started, finished, status, message, results
 ) VALUES (
     NULL, :type,
-    :src, :dst, :pkg, :max,
+    :src, :dst, :pkg, :max, :priority,
+    0, :max_attempts, NULL, :retry_of,
+    '', 0, 0, 0,
     :created, NULL, NULL, :status, NULL, NULL
 )
 `
@@ -59,7 +106,13 @@ INSERT INTO jobs (
 // Update is a query for updating an existing job
 const Update = `
 UPDATE jobs
-SET created=:created,
+SET attempts=:attempts,
+    not_before=:not_before,
+    phase=:phase,
+    done=:done,
+    total=:total,
+    bytes=:bytes,
+    created=:created,
     started=:started,
     finished=:finished,
     status=:status,
@@ -69,8 +122,18 @@ WHERE id=:id
 `
 
 const (
-	getJob  = "SELECT * FROM jobs WHERE id=?"
-	nextJob = "SELECT * FROM jobs WHERE status=0 ORDER BY id LIMIT 1"
+	getJob   = "SELECT * FROM jobs WHERE id=?"
+	nextJobs = "SELECT * FROM jobs WHERE status=0 ORDER BY priority DESC, id"
+	// saveProgress only updates the progress of a running Job
+	saveProgress = "UPDATE jobs SET phase=:phase, done=:done, total=:total, bytes=:bytes WHERE id=:id"
+	// queuedJob looks for any New Job with the same arguments
+	queuedJob = "SELECT id FROM jobs WHERE status=0 AND type=? AND src=? AND dst=? AND pkg=? AND max=? ORDER BY id LIMIT 1"
+	// findQueued looks for a New Job with the same arguments, which isn't waiting to be retried
+	findQueued = "SELECT id FROM jobs WHERE status=0 AND type=? AND src=? AND dst=? AND pkg=? AND max=? AND attempts=0 AND (not_before IS NULL OR not_before<=?) ORDER BY id LIMIT 1"
+	// raisePriority increases the priority of a Job, but never lowers it
+	raisePriority = "UPDATE jobs SET priority=? WHERE id=? AND priority<?"
+	// pendingPkg counts the New or Running Jobs of a type for a package
+	pendingPkg = "SELECT COUNT(*) FROM jobs WHERE status<=1 AND type=? AND pkg=?"
 )
 
 // Queries for Cleaning up the Job queue
@@ -80,4 +143,9 @@ const (
 	clearFailedJobs    = "DELETE FROM jobs WHERE status=2"
 	clearCancelledJobs = "DELETE FROM jobs WHERE status=3"
 	clearCompletedJobs = "DELETE FROM jobs WHERE status=4"
+	// expiredJobs finds the Jobs with a status which finished before a cutoff
+	expiredJobs = "SELECT * FROM jobs WHERE status=? AND finished<? ORDER BY id"
+	deleteJob   = "DELETE FROM jobs WHERE id=?"
+	// statusIDs lists the IDs of the Jobs with a status
+	statusIDs = "SELECT id FROM jobs WHERE status=?"
 )
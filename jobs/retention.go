@@ -0,0 +1,246 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package jobs
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	log "github.com/DataDrake/waterlog"
+	"github.com/getsolus/ferryd/config"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// PruneInterval is the time between checks for finished Jobs which are past their retention
+const PruneInterval = time.Hour
+
+const (
+	// archiveFormat names each archive of pruned Jobs by the time it was written
+	archiveFormat = "jobs-20060102T150405Z.jsonl.gz"
+	// archiveGlob matches the archives of pruned Jobs
+	archiveGlob = "jobs-*.jsonl.gz"
+)
+
+// retention is the number of days to keep the Jobs with a particular status
+type retention struct {
+	status JobStatus
+	days   int
+}
+
+// retentions lists the number of days to keep each kind of finished Job
+func retentions(r config.Retention) []retention {
+	return []retention{
+		{Completed, r.Completed},
+		{Failed, r.Failed},
+		{Cancelled, r.Cancelled},
+	}
+}
+
+// startPruning creates a goroutine which periodically prunes old Jobs, until the Store is closed
+func (s *Store) startPruning() {
+	go func() {
+		ticker := time.NewTicker(PruneInterval)
+		defer ticker.Stop()
+		for {
+			if n, err := s.Prune(config.Current.Retention, time.Now()); err != nil {
+				log.Errorf("Failed to prune old jobs, reason: '%s'\n", err.Error())
+			} else if n > 0 {
+				log.Infof("Archived and pruned %d old jobs\n", n)
+			}
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				s.done <- true
+				return
+			}
+		}
+	}()
+}
+
+// stopPruning waits for the pruning goroutine to shut down
+func (s *Store) stopPruning() {
+	s.stop <- true
+	<-s.done
+}
+
+// Prune writes finished Jobs which are older than their retention to a new archive, and then removes them from the DB
+func (s *Store) Prune(r config.Retention, now time.Time) (n int, err error) {
+	s.Lock()
+	defer s.Unlock()
+	// Find the Jobs past their retention
+	var list List
+	for _, ret := range retentions(r) {
+		if ret.days <= 0 {
+			continue
+		}
+		var old List
+		cutoff := now.AddDate(0, 0, -ret.days).UTC()
+		if err = s.db.Select(&old, expiredJobs, ret.status, cutoff); err != nil {
+			return 0, fmt.Errorf("Failed to read expired jobs, reason: '%s'", err.Error())
+		}
+		list = append(list, old...)
+	}
+	if len(list) == 0 {
+		return
+	}
+	// Keep a copy before they are gone
+	dir := config.Current.JobArchivePath()
+	if err = writeArchive(dir, list, now); err != nil {
+		return 0, fmt.Errorf("Failed to archive expired jobs, reason: '%s'", err.Error())
+	}
+	// Remove them in a single transaction
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return
+	}
+	for _, j := range list {
+		if _, err = tx.Exec(deleteJob, j.ID); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("Failed to remove job '%d', reason: '%s'", j.ID, err.Error())
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return
+	}
+	// Their logs went into the archive with them
+	ids := make([]int, len(list))
+	for i, j := range list {
+		ids[i] = j.ID
+	}
+	removeLogs(ids)
+	// Make room for the next archive
+	if err = rotateArchives(dir, r.Archives); err != nil {
+		log.Warnf("Failed to remove old job archives, reason: '%s'\n", err.Error())
+		err = nil
+	}
+	return len(list), nil
+}
+
+// archived is a pruned Job, along with its log
+type archived struct {
+	*Job
+	Log string `json:"log,omitempty"`
+}
+
+// writeArchive saves a list of Jobs as gzipped JSON lines, one Job per line
+func writeArchive(dir string, list List, now time.Time) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	name := filepath.Join(dir, now.UTC().Format(archiveFormat))
+	// Write to a temporary file so that a partial archive is never left behind
+	tmp := name + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(f)
+	enc := json.NewEncoder(gz)
+	for _, j := range list {
+		a := archived{Job: j}
+		// Not every Job has a log
+		if raw, rerr := ioutil.ReadFile(LogPath(j.ID)); rerr == nil {
+			a.Log = string(raw)
+		}
+		if err = enc.Encode(a); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// rotateArchives removes the oldest archives, leaving at most "keep" of them, or all of them if "keep" is 0
+func rotateArchives(dir string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	names, err := filepath.Glob(filepath.Join(dir, archiveGlob))
+	if err != nil {
+		return err
+	}
+	if len(names) <= keep {
+		return nil
+	}
+	// Names sort by the time they were written
+	sort.Strings(names)
+	for _, name := range names[:len(names)-keep] {
+		if err = os.Remove(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadArchives finds the pruned Jobs matching a Filter in every archive under "dir", oldest archive first
+func ReadArchives(dir string, f Filter) (list List, err error) {
+	names, err := filepath.Glob(filepath.Join(dir, archiveGlob))
+	if err != nil {
+		return
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if list, err = readArchive(name, f, list); err != nil {
+			return nil, fmt.Errorf("Failed to read job archive '%s', reason: '%s'", name, err.Error())
+		}
+	}
+	return
+}
+
+// readArchive appends the Jobs matching a Filter from a single archive to a List
+func readArchive(name string, f Filter, list List) (List, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return list, err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return list, err
+	}
+	defer gz.Close()
+	dec := json.NewDecoder(gz)
+	for {
+		var a archived
+		if err = dec.Decode(&a); err == io.EOF {
+			return list, nil
+		}
+		if err != nil {
+			return list, err
+		}
+		if a.Job != nil && f.Match(a.Job) {
+			list = append(list, a.Job)
+		}
+	}
+}
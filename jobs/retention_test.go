@@ -0,0 +1,147 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package jobs
+
+import (
+	"github.com/getsolus/ferryd/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var pruneTests = []struct {
+	status JobStatus
+	// age is how long ago the Job finished
+	age    time.Duration
+	pruned bool
+}{
+	{Completed, 10 * 24 * time.Hour, true},
+	{Completed, 24 * time.Hour, false},
+	{Failed, 100 * 24 * time.Hour, false},
+	{Cancelled, 4 * 24 * time.Hour, true},
+	{Cancelled, 2 * 24 * time.Hour, false},
+	{New, 0, false},
+}
+
+func TestPrune(t *testing.T) {
+	s := testStore(t)
+	now := time.Date(2020, 12, 31, 11, 5, 0, 0, time.UTC)
+	ids := make([]int, len(pruneTests))
+	for i, test := range pruneTests {
+		j := &Job{Type: Check, Src: "unstable"}
+		ids[i] = mustPush(t, s, j)
+		if test.status == New {
+			continue
+		}
+		j.Status = test.status
+		j.Finished.Time = now.Add(-test.age)
+		j.Finished.Valid = true
+		tx := s.db.MustBegin()
+		if err := j.Save(tx); err != nil {
+			t.Fatalf("Failed to finish job %d: %v", j.ID, err)
+		}
+		tx.Commit()
+	}
+	// Give the first Job a log, to go into the archive with it
+	if err := os.MkdirAll(config.Current.JobLogPath(), 0755); err != nil {
+		t.Fatalf("Failed to create the log dir: %v", err)
+	}
+	if err := ioutil.WriteFile(LogPath(ids[0]), []byte("Completed successfully\n"), 0644); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+	// Failed Jobs are kept forever
+	n, err := s.Prune(config.Retention{Completed: 7, Cancelled: 3}, now)
+	if err != nil {
+		t.Fatalf("Failed to prune jobs: %v", err)
+	}
+	archived, err := ReadArchives(config.Current.JobArchivePath(), Filter{})
+	if err != nil {
+		t.Fatalf("Failed to read archives: %v", err)
+	}
+	inArchive := make(map[int]bool)
+	for _, j := range archived {
+		inArchive[j.ID] = true
+	}
+	expected := 0
+	for i, test := range pruneTests {
+		_, err := s.GetJob(ids[i])
+		switch {
+		case test.pruned && err == nil:
+			t.Errorf("Job %d should have been pruned", ids[i])
+		case !test.pruned && err != nil:
+			t.Errorf("Job %d should have been kept, reason: %v", ids[i], err)
+		case test.pruned != inArchive[ids[i]]:
+			t.Errorf("Job %d should be archived: %t", ids[i], test.pruned)
+		}
+		if test.pruned {
+			expected++
+		}
+	}
+	if n != expected {
+		t.Errorf("Expected %d jobs to be pruned, found: %d", expected, n)
+	}
+	if _, err = os.Stat(LogPath(ids[0])); !os.IsNotExist(err) {
+		t.Errorf("Expected the log of job %d to be removed, found: %v", ids[0], err)
+	}
+}
+
+var rotateTests = []struct {
+	count, keep int
+	// left is the number of the newest archives which should remain
+	left int
+}{
+	{3, 0, 3},
+	{3, 5, 3},
+	{3, 3, 3},
+	{5, 2, 2},
+	{2, 1, 1},
+}
+
+func TestRotateArchives(t *testing.T) {
+	for _, test := range rotateTests {
+		dir := t.TempDir()
+		start := time.Date(2020, 12, 31, 11, 5, 0, 0, time.UTC)
+		var names []string
+		for i := 0; i < test.count; i++ {
+			name := filepath.Join(dir, start.AddDate(0, 0, i).Format(archiveFormat))
+			if err := ioutil.WriteFile(name, nil, 0644); err != nil {
+				t.Fatalf("Failed to write archive: %v", err)
+			}
+			names = append(names, name)
+		}
+		// Other files are left alone
+		other := filepath.Join(dir, "notes.txt")
+		if err := ioutil.WriteFile(other, nil, 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if err := rotateArchives(dir, test.keep); err != nil {
+			t.Fatalf("Failed to rotate %d archives keeping %d: %v", test.count, test.keep, err)
+		}
+		for i, name := range append(names, other) {
+			_, err := os.Stat(name)
+			kept := i >= test.count-test.left
+			switch {
+			case kept && err != nil:
+				t.Errorf("Rotating %d archives keeping %d should leave '%s', reason: %v", test.count, test.keep, filepath.Base(name), err)
+			case !kept && !os.IsNotExist(err):
+				t.Errorf("Rotating %d archives keeping %d should remove '%s'", test.count, test.keep, filepath.Base(name))
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package jobs
+
+import (
+	"time"
+)
+
+const (
+	// DefaultMaxAttempts is the number of times a Job is tried, unless otherwise specified
+	DefaultMaxAttempts = 3
+	// RetryDelay is how long to wait before trying a Job for the second time
+	RetryDelay = 30 * time.Second
+	// MaxRetryDelay is the longest time to wait before trying a Job again
+	MaxRetryDelay = time.Hour
+)
+
+// Backoff works out how long to wait before trying a Job again, doubling after each attempt
+func Backoff(attempts int) time.Duration {
+	delay := RetryDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= MaxRetryDelay {
+			return MaxRetryDelay
+		}
+	}
+	return delay
+}
+
+// Waiting checks if a Job is being held back until its next attempt
+func (j *Job) Waiting() bool {
+	return j.Status == New && j.NotBefore.Valid && j.NotBefore.Time.After(time.Now().UTC())
+}
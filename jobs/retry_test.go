@@ -0,0 +1,85 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+var backoffTests = []struct {
+	attempts int
+	delay    time.Duration
+}{
+	{0, 30 * time.Second},
+	{1, 30 * time.Second},
+	{2, time.Minute},
+	{3, 2 * time.Minute},
+	{7, 32 * time.Minute},
+	{8, time.Hour},
+	{100, time.Hour},
+}
+
+func TestBackoff(t *testing.T) {
+	for _, test := range backoffTests {
+		if delay := Backoff(test.attempts); delay != test.delay {
+			t.Errorf("Backoff after %d attempts should be %s, found: %s", test.attempts, test.delay, delay)
+		}
+	}
+}
+
+var notBeforeTests = []struct {
+	name  string
+	delay time.Duration
+	// claimable is whether the retry can be claimed right away
+	claimable bool
+}{
+	{"waiting", time.Hour, false},
+	{"due", -time.Second, true},
+}
+
+func TestNotBefore(t *testing.T) {
+	for _, test := range notBeforeTests {
+		s := testStore(t)
+		id := mustPush(t, s, &Job{Type: Index, Src: "unstable"})
+		// Fail the first attempt, like a Worker does for a retryable error
+		j, _, err := s.Claim()
+		if err != nil {
+			t.Fatalf("%s: failed to claim job %d: %v", test.name, id, err)
+		}
+		j.Status = New
+		j.NotBefore.Time = time.Now().UTC().Add(test.delay)
+		j.NotBefore.Valid = true
+		if err = s.Retire(j); err != nil {
+			t.Fatalf("%s: failed to retire job %d: %v", test.name, id, err)
+		}
+		// Jobs behind a waiting retry, even for the same repo, are not held up
+		other := mustPush(t, s, &Job{Type: Index, Src: "unstable", Priority: Low})
+		j, _, err = s.Claim()
+		if err != nil {
+			t.Fatalf("%s: failed to claim a job: %v", test.name, err)
+		}
+		switch {
+		case test.claimable && j.ID != id:
+			t.Errorf("%s: expected the retry of job %d, claimed job %d", test.name, id, j.ID)
+		case test.claimable && j.Attempts != 2:
+			t.Errorf("%s: expected attempt 2, found: %d", test.name, j.Attempts)
+		case !test.claimable && j.ID != other:
+			t.Errorf("%s: expected job %d, claimed job %d", test.name, other, j.ID)
+		}
+	}
+}
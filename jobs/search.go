@@ -0,0 +1,135 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package jobs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultLimit is the number of Jobs in a page of search results, when not otherwise specified
+	DefaultLimit = 50
+	// MaxLimit is the largest number of Jobs in a page of search results
+	MaxLimit = 500
+)
+
+// Filter narrows down a search for Jobs, where each field is ignored if left as its zero value
+type Filter struct {
+	// Status of the Jobs, which is only used when HasStatus is set, since New is 0
+	Status    JobStatus
+	HasStatus bool
+	// Type of the Jobs
+	Type JobType
+	// Repo used by the Jobs, as either the source or the destination
+	Repo string
+	// Since and Until bound the creation time of the Jobs
+	Since time.Time
+	Until time.Time
+	// Limit is the number of Jobs in each page
+	Limit int
+	// Cursor is the "next" value from the previous page
+	Cursor int
+}
+
+// where builds the conditions for a search query, along with their arguments
+func (f Filter) where() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	if f.HasStatus {
+		conds = append(conds, "status=?")
+		args = append(args, f.Status)
+	}
+	if f.Type != Invalid {
+		conds = append(conds, "type=?")
+		args = append(args, f.Type)
+	}
+	if len(f.Repo) > 0 {
+		conds = append(conds, "(src=? OR dst=?)")
+		args = append(args, f.Repo, f.Repo)
+	}
+	if !f.Since.IsZero() {
+		conds = append(conds, "created>=?")
+		args = append(args, f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		conds = append(conds, "created<?")
+		args = append(args, f.Until.UTC())
+	}
+	// Pages continue below the last ID of the previous page
+	if f.Cursor > 0 {
+		conds = append(conds, "id<?")
+		args = append(args, f.Cursor)
+	}
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// Match checks if a Job satisfies the conditions of a Filter, the same way as a search would
+func (f Filter) Match(j *Job) bool {
+	switch {
+	case f.HasStatus && j.Status != f.Status:
+		return false
+	case f.Type != Invalid && j.Type != f.Type:
+		return false
+	case len(f.Repo) > 0 && j.Src != f.Repo && j.Dst != f.Repo:
+		return false
+	case !f.Since.IsZero() && j.Created.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !j.Created.Time.Before(f.Until):
+		return false
+	case f.Cursor > 0 && j.ID >= f.Cursor:
+		return false
+	}
+	return true
+}
+
+// Search finds a page of the Jobs matching a Filter, newest first. If there are more
+// results, "next" is the Cursor for the following page, otherwise it is 0.
+func (s *Store) Search(f Filter) (list List, next int, err error) {
+	switch {
+	case f.Limit <= 0:
+		f.Limit = DefaultLimit
+	case f.Limit > MaxLimit:
+		f.Limit = MaxLimit
+	}
+	where, args := f.where()
+	// Ask for one more than needed, to find out if there's another page
+	args = append(args, f.Limit+1)
+	if err = s.db.Select(&list, searchJobs+where+searchOrder, args...); err != nil {
+		err = fmt.Errorf("Failed to search jobs, reason: '%s'", err.Error())
+		return
+	}
+	if len(list) > f.Limit {
+		list = list[:f.Limit]
+		next = list[f.Limit-1].ID
+	}
+	return
+}
+
+// Count gets the number of Jobs matching a Filter, ignoring its Limit and Cursor
+func (s *Store) Count(f Filter) (count int, err error) {
+	f.Cursor = 0
+	where, args := f.where()
+	if err = s.db.Get(&count, countJobs+where, args...); err != nil {
+		err = fmt.Errorf("Failed to count jobs, reason: '%s'", err.Error())
+	}
+	return
+}
@@ -0,0 +1,83 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package jobs
+
+import (
+	"testing"
+)
+
+var searchPageTests = []struct {
+	name         string
+	total, limit int
+	// pages are the IDs expected on each page, newest first
+	pages [][]int
+}{
+	{"no jobs", 0, 2, [][]int{{}}},
+	{"less than a page", 1, 2, [][]int{{1}}},
+	{"exactly a page", 2, 2, [][]int{{2, 1}}},
+	{"one more than a page", 3, 2, [][]int{{3, 2}, {1}}},
+	{"exactly two pages", 4, 2, [][]int{{4, 3}, {2, 1}}},
+	{"one per page", 3, 1, [][]int{{3}, {2}, {1}}},
+}
+
+func TestSearchPages(t *testing.T) {
+	for _, test := range searchPageTests {
+		s := testStore(t)
+		for i := 0; i < test.total; i++ {
+			mustPush(t, s, &Job{Type: Check, Src: "unstable"})
+		}
+		f := Filter{Limit: test.limit}
+		for n, page := range test.pages {
+			list, next, err := s.Search(f)
+			if err != nil {
+				t.Fatalf("%s: failed to search page %d: %v", test.name, n, err)
+			}
+			if len(list) != len(page) {
+				t.Fatalf("%s: expected %d jobs on page %d, found %d", test.name, len(page), n, len(list))
+			}
+			for i, id := range page {
+				if list[i].ID != id {
+					t.Errorf("%s: expected job %d at %d on page %d, found job %d", test.name, id, i, n, list[i].ID)
+				}
+			}
+			last := n == len(test.pages)-1
+			switch {
+			case last && next != 0:
+				t.Errorf("%s: expected no page after page %d, found cursor %d", test.name, n, next)
+			case !last && next != page[len(page)-1]:
+				t.Errorf("%s: expected cursor %d after page %d, found %d", test.name, page[len(page)-1], n, next)
+			}
+			f.Cursor = next
+		}
+	}
+}
+
+func TestCount(t *testing.T) {
+	s := testStore(t)
+	for _, src := range []string{"unstable", "unstable", "stable"} {
+		mustPush(t, s, &Job{Type: Check, Src: src})
+	}
+	// The Limit and Cursor of a page don't change the total
+	f := Filter{Repo: "unstable", Limit: 1, Cursor: 2}
+	count, err := s.Count(f)
+	if err != nil {
+		t.Fatalf("Failed to count jobs: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 jobs for 'unstable', found: %d", count)
+	}
+}
@@ -16,6 +16,10 @@
 
 package jobs
 
+import (
+	"fmt"
+)
+
 // JobStatus indicates the status of a Job in the DB
 type JobStatus int
 
@@ -39,3 +43,21 @@ var statusMap = map[JobStatus]string{
 	Cancelled: "cancelled",
 	Completed: "completed",
 }
+
+// String gets the name of a JobStatus
+func (s JobStatus) String() string {
+	return statusMap[s]
+}
+
+// ParseStatus converts the name of a JobStatus to its value, where "queued" is the same as "new"
+func ParseStatus(name string) (JobStatus, error) {
+	if name == "queued" {
+		return New, nil
+	}
+	for s, n := range statusMap {
+		if n == name {
+			return s, nil
+		}
+	}
+	return New, fmt.Errorf("invalid job status '%s', must be one of: queued, running, failed, cancelled, completed", name)
+}
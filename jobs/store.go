@@ -17,6 +17,8 @@
 package jobs
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	log "github.com/DataDrake/waterlog"
@@ -30,6 +32,10 @@ import (
 var (
 	// ErrNoJobReady is returned when there are no available jobs or the next job is blocked by a running job
 	ErrNoJobReady = errors.New("No jobs ready to run")
+	// ErrJobFinished is returned when trying to cancel a job which has already finished
+	ErrJobFinished = errors.New("Job has already finished")
+	// ErrNotFailed is returned when trying to retry a job which has not failed
+	ErrNotFailed = errors.New("Only failed jobs can be retried")
 )
 
 const (
@@ -43,10 +49,20 @@ const (
 type Store struct {
 	sync.Mutex
 
-	db   *sqlx.DB
-	next *Job
-	stop chan bool
-	done chan bool
+	db       *sqlx.DB
+	next     *Job
+	running  map[int]context.CancelFunc
+	locks    repoLocks
+	instant  []string
+	coalesce map[JobType]bool
+	classes  classLimits
+	ready    chan struct{}
+	// paused stops jobs from being claimed, see Mode
+	paused      bool
+	modeChanged chan struct{}
+	events      *Events
+	stop        chan bool
+	done        chan bool
 }
 
 // NewStore creates a fully initialized Store and sets up Bolt Buckets as needed
@@ -60,20 +76,64 @@ func NewStore() (s *Store, err error) {
 	db.SetMaxOpenConns(1)
 	// Create "jobs" table if missing
 	db.MustExec(JobSchema)
+	if err = upgrade(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	// Index the columns used for searches
+	db.MustExec(JobIndexes)
+	coalesce, err := coalescing(config.Current.Coalesce)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
 	s = &Store{
-		db:   db,
-		next: nil,
-		stop: make(chan bool),
-		done: make(chan bool),
+		db:          db,
+		next:        nil,
+		running:     make(map[int]context.CancelFunc),
+		locks:       make(repoLocks),
+		coalesce:    coalesce,
+		classes:     newClassLimits(config.Current.Limits),
+		ready:       make(chan struct{}),
+		modeChanged: make(chan struct{}),
+		events:      NewEvents(),
+		stop:        make(chan bool),
+		done:        make(chan bool),
+	}
+	// reset running jobs
+	if err = s.UnclaimRunning(); err != nil {
+		return
 	}
-	// reset running jobs and return
-	err = s.UnclaimRunning()
+	// Clean up old jobs in the background
+	s.startPruning()
 	return
 }
 
+// upgrade adds any columns which are missing from an existing Jobs table
+func upgrade(db *sqlx.DB) error {
+	var names []string
+	if err := db.Select(&names, listColumns); err != nil {
+		return fmt.Errorf("Failed to read the jobs table, reason: '%s'", err.Error())
+	}
+	existing := make(map[string]bool)
+	for _, name := range names {
+		existing[name] = true
+	}
+	for _, col := range jobColumns {
+		if existing[col.Name] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE jobs ADD COLUMN %s %s", col.Name, col.Def)); err != nil {
+			return fmt.Errorf("Failed to add column '%s' to the jobs table, reason: '%s'", col.Name, err.Error())
+		}
+	}
+	return nil
+}
+
 // Close will clean up our private job database
 func (s *Store) Close() error {
 	if s.db != nil {
+		s.stopPruning()
 		err := s.db.Close()
 		s.db = nil
 		return err
@@ -81,10 +141,15 @@ func (s *Store) Close() error {
 	return nil
 }
 
+// Events gets the notifications of changes to Jobs and the repos they modify
+func (s *Store) Events() *Events {
+	return s.events
+}
+
 // GetJob retrieves a Job from the DB
 func (s *Store) GetJob(id int) (j *Job, err error) {
 	j = &Job{}
-	err = s.db.Get(&j, getJob, id)
+	err = s.db.Get(j, getJob, id)
 	return
 }
 
@@ -98,16 +163,35 @@ func (s *Store) UnclaimRunning() (err error) {
 	return
 }
 
-// Push inserts a new Job into the queue
-func (s *Store) Push(j *Job) (id int, err error) {
+// Push inserts a new Job into the queue, unless its JobType coalesces and an identical Job
+// is already waiting, in which case the ID of that Job is returned and "merged" is true
+func (s *Store) Push(j *Job) (id int, merged bool, err error) {
+	return s.push(j, s.coalesce[j.Type])
+}
+
+// push inserts a new Job into the queue, merging it with an identical waiting Job if "coalesce" is set
+func (s *Store) push(j *Job, coalesce bool) (id int, merged bool, err error) {
+	var tx *sqlx.Tx
 	s.Lock()
 	// Set Job parameters
 	j.Status = New
+	if j.Priority == Default {
+		j.Priority = DefaultPriority(j.Type)
+	}
+	if j.MaxAttempts < 1 {
+		j.MaxAttempts = DefaultMaxAttempts
+	}
 	j.Created.Time = time.Now().UTC()
 	j.Created.Valid = true
+	// Look for a Job to merge with
+	if coalesce {
+		if id, err = s.findQueued(j); err != nil || id != 0 {
+			merged = id != 0
+			goto UNLOCK
+		}
+	}
 	// Start a DB transaction
-	tx, err := s.db.Beginx()
-	if err != nil {
+	if tx, err = s.db.Beginx(); err != nil {
 		goto UNLOCK
 	}
 	// Insert the New Job
@@ -117,25 +201,102 @@ func (s *Store) Push(j *Job) (id int, err error) {
 	}
 	id = j.ID
 	// Complete the transaction
-	err = tx.Commit()
+	if err = tx.Commit(); err != nil {
+		goto UNLOCK
+	}
+	s.events.jobChanged(j)
+	s.wake()
 UNLOCK:
 	s.Unlock()
-	return id, err
+	return id, merged, err
 }
 
+// FindQueued gets the ID of a New Job with the same arguments as "j", including one waiting to
+// be retried, or 0 if there isn't one. Unlike coalescing, it never changes the queued Job.
+func (s *Store) FindQueued(j *Job) (id int, err error) {
+	s.Lock()
+	if err = s.db.Get(&id, queuedJob, j.Type, j.Src, j.Dst, j.Pkg, j.Max); err == sql.ErrNoRows {
+		err = nil
+	}
+	s.Unlock()
+	return
+}
+
+// findQueued looks for a New Job with the same arguments as "j", raising its priority to match if needed.
+// Jobs which have already failed are left alone, so a retry doesn't absorb a fresh request.
+func (s *Store) findQueued(j *Job) (id int, err error) {
+	if err = s.db.Get(&id, findQueued, j.Type, j.Src, j.Dst, j.Pkg, j.Max, time.Now().UTC()); err != nil {
+		if err == sql.ErrNoRows {
+			err = nil
+		}
+		return
+	}
+	// The waiting Job now has to satisfy the most urgent request
+	_, err = s.db.Exec(raisePriority, j.Priority, id, j.Priority)
+	return
+}
+
+// Pending checks if a Job of this type for this package is New or Running
+func (s *Store) Pending(t JobType, pkg string) (pending bool, err error) {
+	var count int
+	s.Lock()
+	err = s.db.Get(&count, pendingPkg, t, pkg)
+	s.Unlock()
+	pending = count > 0
+	return
+}
+
+// findNewJob picks the oldest New job which doesn't need any repos in use by running jobs,
+// and whose JobClass isn't already running as many jobs as it is allowed
 func (s *Store) findNewJob() {
-	var next Job
-	if err := s.db.Get(&next, nextJob); err != nil {
+	s.next = nil
+	var list List
+	if err := s.db.Select(&list, nextJobs); err != nil {
+		log.Errorf("Failed to read new jobs, reason: '%s'", err.Error())
 		return
 	}
-	s.next = &next
+	// Repos wanted by skipped jobs are reserved, so that jobs for each repo still run in order
+	reserved := make(repoLocks)
+	for _, j := range list {
+		// Jobs waiting to be retried don't hold up other jobs
+		if j.Waiting() {
+			continue
+		}
+		ls := s.lockSet(j)
+		if s.locks.available(ls) && reserved.available(ls) && s.classes.available(j) {
+			s.next = j
+			return
+		}
+		reserved.acquire(ls)
+	}
+}
+
+// Ready gets a channel which is closed the next time a Job may have become claimable, so that
+// idle workers can wait for it instead of polling. It must be called before trying to Claim.
+func (s *Store) Ready() <-chan struct{} {
+	s.Lock()
+	defer s.Unlock()
+	return s.ready
 }
 
-// Claim gets the first available job, if one exists and is not blocked by running jobs
-func (s *Store) Claim() (j *Job, err error) {
+// wake lets every idle worker know to try claiming a Job again
+func (s *Store) wake() {
+	close(s.ready)
+	s.ready = make(chan struct{})
+}
+
+// Claim gets the first available job, if one exists and is not blocked by running jobs,
+// along with a Context which is cancelled if the job is cancelled while running
+func (s *Store) Claim() (j *Job, ctx context.Context, err error) {
 	var tx *sqlx.Tx
+	var cancel context.CancelFunc
 	s.Lock()
-	if s.next == nil {
+	// nothing is handed out while paused
+	if s.paused {
+		err = ErrNoJobReady
+		goto UNLOCK
+	}
+	if s.findNewJob(); s.next == nil {
 		err = ErrNoJobReady
 		goto UNLOCK
 	}
@@ -143,6 +304,12 @@ func (s *Store) Claim() (j *Job, err error) {
 	s.next.Status = Running
 	s.next.Started.Time = time.Now().UTC()
 	s.next.Started.Valid = true
+	s.next.Attempts++
+	// Start over on the progress of earlier attempts
+	s.next.Phase = ""
+	s.next.Done = 0
+	s.next.Total = 0
+	s.next.Bytes = 0
 	// Start a DB transaction
 	tx, err = s.db.Beginx()
 	if err != nil {
@@ -157,15 +324,42 @@ func (s *Store) Claim() (j *Job, err error) {
 	if err = tx.Commit(); err != nil {
 		goto UNLOCK
 	}
-	// find the next replacement job
+	// keep other jobs away from its repos
 	j, s.next = s.next, nil
+	j.locks = s.lockSet(j)
+	s.locks.acquire(j.locks)
+	s.classes.acquire(j)
+	// allow the job to report its progress
+	j.progress = &Progress{
+		job:  j,
+		save: s.saveProgress,
+	}
+	// capture the output of the job, which still runs without it
+	if j.log, err = openLog(j, s.events); err != nil {
+		log.Warnf("Failed to open the log of job '%d', reason: '%s'\n", j.ID, err.Error())
+		err = nil
+	}
+	j.Log().Infof("Starting attempt %d of %d: %s\n", j.Attempts, j.MaxAttempts, j.Describe())
+	// allow the job to be cancelled
+	ctx, cancel = context.WithCancel(context.Background())
+	s.running[j.ID] = cancel
+	s.events.jobChanged(j)
 UNLOCK:
-	s.findNewJob()
 	s.Unlock()
 	return
 }
 
-// Retire marks a job as completed and updates the DB record
+// saveProgress updates the progress of a running Job in the DB
+func (s *Store) saveProgress(j *Job) (err error) {
+	s.Lock()
+	if _, err = s.db.NamedExec(saveProgress, j); err == nil {
+		s.events.jobProgress(j)
+	}
+	s.Unlock()
+	return
+}
+
+// Retire marks a job as finished, or puts it back in the queue to be retried, and updates the DB record
 func (s *Store) Retire(j *Job) error {
 	s.Lock()
 	// Start a DB transaction
@@ -173,18 +367,126 @@ func (s *Store) Retire(j *Job) error {
 	if err != nil {
 		goto UNLOCK
 	}
-	// Mark as finished
+	if j.Status == New {
+		// Waiting for another attempt
+		j.Started.Valid = false
+	} else {
+		// Mark as finished
+		j.Finished.Time = time.Now().UTC()
+		j.Finished.Valid = true
+	}
+	if err = j.Save(tx); err != nil {
+		tx.Rollback()
+		goto UNLOCK
+	}
+	// Finish the transaction
+	if err = tx.Commit(); err != nil {
+		goto UNLOCK
+	}
+	s.events.jobChanged(j)
+	// Let subscribers know which repos were modified
+	if j.Status == Completed {
+		for _, name := range j.locks.write {
+			s.events.repoChanged(name)
+		}
+	}
+UNLOCK:
+	// The job has nothing more to say
+	j.log.close()
+	j.log = nil
+	// The job can no longer be cancelled
+	if cancel, ok := s.running[j.ID]; ok {
+		cancel()
+		delete(s.running, j.ID)
+		// Let other jobs use its repos
+		s.locks.release(j.locks)
+		s.classes.release(j)
+		// Jobs waiting on its repos, or a retry of it, may now run
+		s.wake()
+	}
+	s.Unlock()
+	return err
+}
+
+// Cancel stops a Job, removing it from the queue if it hasn't started, or signalling
+// it to stop if it is running. Running jobs are retired by their worker once stopped.
+func (s *Store) Cancel(id int) (j *Job, err error) {
+	var tx *sqlx.Tx
+	s.Lock()
+	// Get the current state of the job
+	j = &Job{}
+	if err = s.db.Get(j, getJob, id); err != nil {
+		goto UNLOCK
+	}
+	switch j.Status {
+	case New:
+	case Running:
+		if cancel, ok := s.running[id]; ok {
+			cancel()
+		}
+		goto UNLOCK
+	default:
+		err = ErrJobFinished
+		goto UNLOCK
+	}
+	// Mark as cancelled
+	j.Status = Cancelled
 	j.Finished.Time = time.Now().UTC()
 	j.Finished.Valid = true
+	if j.Attempts > 0 {
+		j.Message.String = "Cancelled while waiting to be retried"
+	} else {
+		j.Message.String = "Cancelled before execution"
+	}
+	j.Message.Valid = true
+	// Start a DB transaction
+	if tx, err = s.db.Beginx(); err != nil {
+		goto UNLOCK
+	}
+	// Save the status change
 	if err = j.Save(tx); err != nil {
 		tx.Rollback()
 		goto UNLOCK
 	}
 	// Finish the transaction
-	err = tx.Commit()
+	if err = tx.Commit(); err != nil {
+		goto UNLOCK
+	}
+	// Make sure it won't be claimed
+	if s.next != nil && s.next.ID == id {
+		s.next = nil
+	}
+	s.events.jobChanged(j)
+	// Jobs held back behind it may now run
+	s.wake()
 UNLOCK:
 	s.Unlock()
-	return err
+	return
+}
+
+// Retry queues a new copy of a failed Job, linked to the original
+func (s *Store) Retry(id int) (int, bool, error) {
+	// Get the original job
+	orig, err := s.GetJob(id)
+	if err != nil {
+		return -1, false, err
+	}
+	if orig.Status != Failed {
+		return -1, false, ErrNotFailed
+	}
+	// Queue up a fresh copy
+	j := &Job{
+		Type:        orig.Type,
+		Src:         orig.Src,
+		Dst:         orig.Dst,
+		Pkg:         orig.Pkg,
+		Max:         orig.Max,
+		Priority:    orig.Priority,
+		MaxAttempts: orig.MaxAttempts,
+		RetryOf:     orig.ID,
+	}
+	// Never merge, so that the new Job keeps its link to the original
+	return s.push(j, false)
 }
 
 // Active will attempt to return a list of active jobs within
@@ -222,11 +524,22 @@ func (s *Store) Failed() (list List, err error) {
 	return
 }
 
+// statusIDs lists the IDs of every Job with a status, so that their logs can be removed along with them
+func (s *Store) statusIDs(status JobStatus) (ids []int) {
+	if err := s.db.Select(&ids, statusIDs, status); err != nil {
+		log.Warnf("Failed to list %s jobs, reason: '%s'\n", status, err.Error())
+	}
+	return
+}
+
 // ResetCompleted will remove all completion records from our store and reset the pointer
 func (s *Store) ResetCompleted() (err error) {
 	s.Lock()
+	ids := s.statusIDs(Completed)
 	if _, err = s.db.Exec(clearCompletedJobs); err != nil {
 		err = fmt.Errorf("Failed to clear completed jobs, reason: '%s'", err.Error())
+	} else {
+		removeLogs(ids)
 	}
 	s.Unlock()
 	return
@@ -235,8 +548,11 @@ func (s *Store) ResetCompleted() (err error) {
 // ResetFailed will remove all fail records from our store and reset the pointer
 func (s *Store) ResetFailed() (err error) {
 	s.Lock()
+	ids := s.statusIDs(Failed)
 	if _, err = s.db.Exec(clearFailedJobs); err != nil {
 		err = fmt.Errorf("Failed to clear failed jobs, reason: '%s'", err.Error())
+	} else {
+		removeLogs(ids)
 	}
 	s.Unlock()
 	return
@@ -245,9 +561,15 @@ func (s *Store) ResetFailed() (err error) {
 // ResetQueued will remove all unexecuted records from our store and reset the pointer
 func (s *Store) ResetQueued() (err error) {
 	s.Lock()
+	// Jobs waiting to be retried already have a log
+	ids := s.statusIDs(New)
 	if _, err = s.db.Exec(clearQueuedJobs); err != nil {
 		err = fmt.Errorf("Failed to clear queued jobs, reason: '%s'", err.Error())
+	} else {
+		removeLogs(ids)
 	}
+	// Make sure the next job won't be claimed
+	s.next = nil
 	s.Unlock()
 	return
 }
@@ -0,0 +1,225 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package jobs
+
+import (
+	"context"
+	"github.com/getsolus/ferryd/config"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"testing"
+)
+
+// testStore creates a Store backed by an in-memory DB, with the config pointed at temporary directories
+func testStore(t *testing.T) *Store {
+	config.Current = &config.File{
+		BaseDir:  t.TempDir(),
+		BuildDir: t.TempDir(),
+	}
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	// Every connection to ":memory:" gets its own DB
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		db.Close()
+	})
+	db.MustExec(JobSchema)
+	if err = upgrade(db); err != nil {
+		t.Fatalf("Failed to upgrade DB: %v", err)
+	}
+	db.MustExec(JobIndexes)
+	coalesce, err := coalescing(nil)
+	if err != nil {
+		t.Fatalf("Failed to read coalesce config: %v", err)
+	}
+	return &Store{
+		db:          db,
+		running:     make(map[int]context.CancelFunc),
+		locks:       make(repoLocks),
+		coalesce:    coalesce,
+		classes:     newClassLimits(config.Limits{}),
+		ready:       make(chan struct{}),
+		modeChanged: make(chan struct{}),
+		events:      NewEvents(),
+	}
+}
+
+// mustPush queues a Job without merging it, failing the test if it can't
+func mustPush(t *testing.T, s *Store, j *Job) int {
+	id, _, err := s.push(j, false)
+	if err != nil {
+		t.Fatalf("Failed to push job: %v", err)
+	}
+	return id
+}
+
+var findNewJobTests = []struct {
+	name    string
+	instant []string
+	running []*Job
+	queued  []*Job
+	// next is the index of the queued Job which should be picked, or -1 for none
+	next int
+}{
+	{
+		name:   "nothing running",
+		queued: []*Job{{Type: Index, Src: "unstable"}},
+		next:   0,
+	},
+	{
+		name:    "writer blocks the same repo",
+		running: []*Job{{Type: Index, Src: "unstable"}},
+		queued:  []*Job{{Type: Index, Src: "unstable"}, {Type: Check, Src: "stable"}},
+		next:    1,
+	},
+	{
+		name:    "readers share a repo",
+		running: []*Job{{Type: Check, Src: "unstable"}},
+		queued:  []*Job{{Type: Compare, Src: "unstable", Dst: "stable"}},
+		next:    0,
+	},
+	{
+		name:    "blocked writer reserves its repo",
+		running: []*Job{{Type: Check, Src: "unstable"}},
+		queued:  []*Job{{Type: Index, Src: "unstable"}, {Type: Check, Src: "unstable"}},
+		next:    -1,
+	},
+	{
+		name:    "blocked reader reserves its repo",
+		running: []*Job{{Type: Index, Src: "stable"}},
+		queued:  []*Job{{Type: Compare, Src: "unstable", Dst: "stable"}, {Type: Index, Src: "unstable"}},
+		next:    -1,
+	},
+	{
+		name:    "pool writers exclude each other",
+		running: []*Job{{Type: Delta, Src: "unstable"}},
+		queued:  []*Job{{Type: Sync, Src: "unstable", Dst: "stable"}, {Type: Index, Src: "shannon"}},
+		next:    1,
+	},
+	{
+		name:    "transit waits for instant-transit repos",
+		instant: []string{"unstable"},
+		running: []*Job{{Type: Check, Src: "unstable"}},
+		queued:  []*Job{{Type: TransitPackage, Pkg: "nano.tram"}, {Type: Check, Src: "stable"}},
+		next:    1,
+	},
+	{
+		name:    "transit ignores other repos",
+		instant: []string{"unstable"},
+		running: []*Job{{Type: Check, Src: "stable"}},
+		queued:  []*Job{{Type: TransitPackage, Pkg: "nano.tram"}},
+		next:    0,
+	},
+}
+
+func TestFindNewJob(t *testing.T) {
+	for _, test := range findNewJobTests {
+		s := testStore(t)
+		s.instant = test.instant
+		for _, j := range test.running {
+			s.locks.acquire(s.lockSet(j))
+		}
+		ids := make([]int, len(test.queued))
+		for i, j := range test.queued {
+			// Keep them in the order given
+			j.Priority = Normal
+			ids[i] = mustPush(t, s, j)
+		}
+		s.findNewJob()
+		switch {
+		case test.next < 0 && s.next != nil:
+			t.Errorf("%s: expected no job, found job %d", test.name, s.next.ID)
+		case test.next >= 0 && s.next == nil:
+			t.Errorf("%s: expected job %d, found none", test.name, ids[test.next])
+		case test.next >= 0 && s.next.ID != ids[test.next]:
+			t.Errorf("%s: expected job %d, found job %d", test.name, ids[test.next], s.next.ID)
+		}
+	}
+}
+
+var claimOrderTests = []struct {
+	name   string
+	queued []*Job
+	// order is the indexes of the queued Jobs in the order they should be claimed
+	order []int
+}{
+	{
+		name: "equal priorities keep queue order",
+		queued: []*Job{
+			{Type: Check, Src: "unstable", Priority: Normal},
+			{Type: Check, Src: "stable", Priority: Normal},
+			{Type: Check, Src: "shannon", Priority: Normal},
+		},
+		order: []int{0, 1, 2},
+	},
+	{
+		name: "highest priority first",
+		queued: []*Job{
+			{Type: Check, Src: "unstable", Priority: Low},
+			{Type: Check, Src: "stable", Priority: Normal},
+			{Type: Check, Src: "shannon", Priority: High},
+		},
+		order: []int{2, 1, 0},
+	},
+	{
+		name: "queue order within each priority",
+		queued: []*Job{
+			{Type: Check, Src: "unstable", Priority: Normal},
+			{Type: Check, Src: "stable", Priority: High},
+			{Type: Check, Src: "shannon", Priority: Low},
+			{Type: Check, Src: "unstable", Priority: High},
+		},
+		order: []int{1, 3, 0, 2},
+	},
+	{
+		name: "default priority by type",
+		queued: []*Job{
+			{Type: Delta, Src: "unstable"},
+			{Type: Check, Src: "stable"},
+			{Type: TransitPackage, Pkg: "nano.tram"},
+		},
+		order: []int{2, 1, 0},
+	},
+}
+
+func TestClaimOrder(t *testing.T) {
+	for _, test := range claimOrderTests {
+		s := testStore(t)
+		ids := make([]int, len(test.queued))
+		for i, j := range test.queued {
+			ids[i] = mustPush(t, s, j)
+		}
+		for _, i := range test.order {
+			j, _, err := s.Claim()
+			if err != nil {
+				t.Fatalf("%s: failed to claim job %d: %v", test.name, ids[i], err)
+			}
+			if j.ID != ids[i] {
+				t.Errorf("%s: expected job %d, claimed job %d", test.name, ids[i], j.ID)
+			}
+			j.Status = Completed
+			if err = s.Retire(j); err != nil {
+				t.Fatalf("%s: failed to retire job %d: %v", test.name, j.ID, err)
+			}
+		}
+		if _, _, err := s.Claim(); err != ErrNoJobReady {
+			t.Errorf("%s: expected no more jobs, found: %v", test.name, err)
+		}
+	}
+}
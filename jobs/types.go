@@ -16,6 +16,11 @@
 
 package jobs
 
+import (
+	"fmt"
+	"strings"
+)
+
 // JobType is a numerical representation of a kind of job
 type JobType int
 
@@ -64,8 +69,27 @@ var typeMap = map[JobType]string{
 	Index:          "Index",
 	Remove:         "Remove",
 	Rescan:         "Rescan",
-	Sync:           "Scan",
+	Sync:           "Sync",
 	TrimObsoletes:  "Trim Obsoletes",
 	TrimPackages:   "Trim Packages",
 	TransitPackage: "Transit Package",
 }
+
+// String gets the name of a JobType
+func (t JobType) String() string {
+	if name, ok := typeMap[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", int(t))
+}
+
+// ParseType converts the name of a JobType to its value, ignoring case and with dashes for spaces, i.e. "transit-package"
+func ParseType(name string) (JobType, error) {
+	want := strings.ReplaceAll(strings.ToLower(name), "-", " ")
+	for t, n := range typeMap {
+		if t != Invalid && strings.ToLower(n) == want {
+			return t, nil
+		}
+	}
+	return Invalid, fmt.Errorf("invalid job type '%s'", name)
+}
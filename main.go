@@ -18,9 +18,13 @@ package main
 
 import (
 	"github.com/getsolus/ferryd/cli"
+	"github.com/getsolus/ferryd/config"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 func main() {
+	if err := config.Load(); err != nil {
+		panic(err.Error())
+	}
 	cli.Root.Run()
 }
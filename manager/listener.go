@@ -18,11 +18,13 @@ package manager
 
 import (
 	log "github.com/DataDrake/waterlog"
+	"github.com/getsolus/ferryd/jobs"
 	"github.com/getsolus/ferryd/manifest"
 	"github.com/getsolus/ferryd/util"
 	"github.com/radu-munteanu/fsnotify"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -58,10 +60,12 @@ func NewListener(baseDir string, mgr *Manager) (tl *Listener, err error) {
 }
 
 // Start creates a gorouting than will wait for events on the incoming directory
-// and process incoming .tram files
+// and process incoming .tram files. While the queue is paused for maintenance, .tram
+// files are left untouched until it is resumed.
 func (tl *Listener) Start() {
 	go func() {
 		for {
+			_, changed := tl.manager.store.Paused()
 			select {
 			case event := <-tl.watcher.Events:
 				// Not interested in subdirs
@@ -71,9 +75,17 @@ func (tl *Listener) Start() {
 				// Filtering on Update events
 				if event.Op&fsnotify.Update == fsnotify.Update {
 					if strings.HasSuffix(event.Name, manifest.Suffix) {
+						if paused, _ := tl.manager.store.Paused(); paused {
+							log.Infof("Holding transit manifest upload until the queue is resumed: '%s'\n", filepath.Base(event.Name))
+							continue
+						}
 						tl.processManifest(filepath.Base(event.Name))
 					}
 				}
+			case <-changed:
+				if paused, _ := tl.manager.store.Paused(); !paused {
+					tl.processHeld()
+				}
 			case <-tl.stop:
 				tl.done <- true
 				return
@@ -88,6 +100,28 @@ func (tl *Listener) Stop() bool {
 	return <-tl.done
 }
 
+// processHeld transits the .tram files waiting in the incoming directory, in order of name,
+// skipping any which already have a transit queued or running
+func (tl *Listener) processHeld() {
+	names, err := filepath.Glob(filepath.Join(tl.base, "*"+manifest.Suffix))
+	if err != nil {
+		log.Errorf("Failed to list held transit manifests, reason: '%s'\n", err.Error())
+		return
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pending, err := tl.manager.store.Pending(jobs.TransitPackage, name)
+		if err != nil {
+			log.Errorf("Failed to check for a queued transit of '%s', reason: '%s'\n", filepath.Base(name), err.Error())
+			continue
+		}
+		if pending {
+			continue
+		}
+		tl.processManifest(filepath.Base(name))
+	}
+}
+
 // processManifest is invoked when a .tram file is closed in our incoming
 // directory. We'll now push it for further processing
 func (tl *Listener) processManifest(name string) {
@@ -103,5 +137,5 @@ func (tl *Listener) processManifest(name string) {
 	}
 	// Transit the new packages
 	log.Infof("Received transit manifest upload: '%s'\n", name)
-	tl.manager.TransitPackage(fullpath)
+	tl.manager.TransitPackage(fullpath, jobs.Default)
 }
@@ -17,6 +17,9 @@
 package manager
 
 import (
+	"context"
+	"fmt"
+	"github.com/getsolus/ferryd/config"
 	"github.com/getsolus/ferryd/jobs"
 	"github.com/getsolus/ferryd/repo"
 	"github.com/jmoiron/sqlx"
@@ -24,9 +27,10 @@ import (
 
 // Manager is responsible for carrying out changes to the repositories
 type Manager struct {
-	db    *sqlx.DB
-	store *jobs.Store
-	pool  *Pool
+	db       *sqlx.DB
+	store    *jobs.Store
+	pool     *Pool
+	schedule *Scheduler
 }
 
 /**************************/
@@ -40,6 +44,10 @@ func NewManager(store *jobs.Store) *Manager {
 	}
 	// Open the DB
 	manager.db = repo.OpenDB()
+	// Workers need to know which repos are linked to on transit before claiming anything
+	if err := manager.updateInstantTransit(); err != nil {
+		panic(err.Error())
+	}
 	// Create and start the pool
 	manager.pool = NewPool(manager)
 	manager.pool.Begin()
@@ -50,19 +58,67 @@ func NewManager(store *jobs.Store) *Manager {
 	}
 	found := false
 	for _, s := range f {
-		if s.Name == "pool" {
+		if s.Name == repo.PoolName {
 			found = true
 			break
 		}
 	}
 	if !found {
-		manager.Create("pool", true)
+		j := &jobs.Job{
+			Type: jobs.Create,
+			Dst:  repo.PoolName,
+		}
+		if err = manager.CreateExecute(context.Background(), j); err != nil {
+			panic(err.Error())
+		}
+	}
+	// Start queueing recurring jobs
+	if manager.schedule, err = NewScheduler(manager, config.Current.Schedules); err != nil {
+		panic(err.Error())
 	}
+	manager.schedule.Start()
 	return manager
 }
 
+// updateInstantTransit tells the store which repos TransitPackage jobs will link into
+func (m *Manager) updateInstantTransit() error {
+	tx, err := m.db.Beginx()
+	if err != nil {
+		return err
+	}
+	rs, err := repo.All(tx)
+	tx.Rollback()
+	if err != nil {
+		return fmt.Errorf("Failed to get the list of repos, reason: '%s'", err.Error())
+	}
+	var names []string
+	for _, r := range rs {
+		if !r.IsPool() && r.InstantTransit {
+			names = append(names, r.Name)
+		}
+	}
+	m.store.SetInstantTransit(names)
+	return nil
+}
+
+// Scheduled lists the recurring jobs and when they will next be queued
+func (m *Manager) Scheduled() []Scheduled {
+	return m.schedule.Upcoming()
+}
+
+// Workers gets the number of jobs which may run at once
+func (m *Manager) Workers() int {
+	return m.pool.Size()
+}
+
+// SetWorkers changes the number of jobs which may run at once, letting running jobs finish first
+func (m *Manager) SetWorkers(n int) error {
+	return m.pool.Resize(n)
+}
+
 // Close shuts-down the manager and closes its database
 func (m *Manager) Close() error {
+	m.schedule.Stop()
 	m.pool.Close()
 	if err := m.store.Close(); err != nil {
 		return err
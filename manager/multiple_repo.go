@@ -17,21 +17,26 @@
 package manager
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/getsolus/ferryd/jobs"
 	"github.com/getsolus/ferryd/repo"
+	"github.com/getsolus/ferryd/util"
 	"github.com/jmoiron/sqlx"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 )
 
 /***************************/
 /* MULTIPLE REPO FUNCTIONS */
 /***************************/
 
-type dualRepoFunc func(left, right *repo.Repo, j *jobs.Job, tx *sqlx.Tx) (d *repo.Diff, err error)
+type dualRepoFunc func(ctx context.Context, left, right *repo.Repo, j *jobs.Job, tx *sqlx.Tx) (d *repo.Diff, err error)
 
 // dualRepoExecute carries out an action on two repos which generates a Diff
-func (m *Manager) dualRepoExecute(dual dualRepoFunc, j *jobs.Job) error {
+func (m *Manager) dualRepoExecute(ctx context.Context, dual dualRepoFunc, j *jobs.Job) error {
 	// Validate the arguments
 	if len(j.Src) == 0 {
 		return errors.New("job is missing a source repo")
@@ -58,14 +63,26 @@ func (m *Manager) dualRepoExecute(dual dualRepoFunc, j *jobs.Job) error {
 	}
 	// CherryPick a single package from one repo to the other
 	var diff *repo.Diff
-	if diff, err = dual(src, dst, j, tx); err != nil {
+	if diff, err = dual(ctx, src, dst, j, tx); err != nil {
 		tx.Rollback()
+		m.revertDisk(j, dst)
+		return err
+	}
+	// Last chance to stop before making the changes permanent
+	if err = ctx.Err(); err != nil {
+		tx.Rollback()
+		m.revertDisk(j, dst)
 		return err
 	}
 	// End the transaction
 	if err = tx.Commit(); err != nil {
+		m.revertDisk(j, dst)
 		return fmt.Errorf("failed to commit the transaction, reason: '%s'", err.Error())
 	}
+	// Keep the changes on disk
+	if err = dst.CommitDisk(); err != nil {
+		j.Log().Warnf("Failed to clean up after changing '%s', reason: '%s'\n", dst.Name, err.Error())
+	}
 	// Save the diff into the job
 	j.Results, err = diff.MarshalBinary()
 	if err != nil {
@@ -74,113 +91,223 @@ func (m *Manager) dualRepoExecute(dual dualRepoFunc, j *jobs.Job) error {
 	return nil
 }
 
+// revertDisk undoes any changes made to a repo on disk after the DB transaction has been rolled back
+func (m *Manager) revertDisk(j *jobs.Job, r *repo.Repo) {
+	if err := r.RevertDisk(); err != nil {
+		j.Log().Errorf("Repo '%s' may not match the DB, reason: '%s'\n", r.Name, err.Error())
+	}
+}
+
 // CherryPick syncs a single package from one repo to another
-func (m *Manager) CherryPick(src, dest, pkg string) (int, error) {
+func (m *Manager) CherryPick(src, dest, pkg string, priority jobs.JobPriority) (int, bool, error) {
 	// Validate the arguments
 	if len(src) == 0 {
-		return -1, errors.New("job is missing a source repo")
+		return -1, false, errors.New("job is missing a source repo")
 	}
 	if len(dest) == 0 {
-		return -1, errors.New("job is missing a destination repo")
+		return -1, false, errors.New("job is missing a destination repo")
 	}
 	if len(pkg) == 0 {
-		return -1, errors.New("job is missing a package name")
+		return -1, false, errors.New("job is missing a package name")
 	}
 	// Create a new job instance
 	j := &jobs.Job{
-		Type: jobs.CherryPick,
-		Src:  src,
-		Dst:  dest,
-		Pkg:  pkg,
+		Type:     jobs.CherryPick,
+		Priority: priority,
+		Src:      src,
+		Dst:      dest,
+		Pkg:      pkg,
 	}
 	// Add the job to the DB
 	return m.store.Push(j)
 }
 
 // CherryPickExecute carries out a CherryPick Job
-func (m *Manager) CherryPickExecute(j *jobs.Job) error {
+func (m *Manager) CherryPickExecute(ctx context.Context, j *jobs.Job) error {
 	// Validate the arguments
 	if len(j.Pkg) == 0 {
 		return errors.New("job is missing a package name")
 	}
-	return m.dualRepoExecute(repo.CherryPick, j)
+	return m.dualRepoExecute(ctx, repo.CherryPick, j)
 }
 
 // Clone creates a new repo as a copy of and existing repo
-func (m *Manager) Clone(src, dst string) (int, error) {
+func (m *Manager) Clone(src, dst string, priority jobs.JobPriority) (int, bool, error) {
 	// Validate the arguments
 	if len(src) == 0 {
-		return -1, errors.New("job is missing a source repo")
+		return -1, false, errors.New("job is missing a source repo")
 	}
 	if len(dst) == 0 {
-		return -1, errors.New("job is missing a destination repo")
+		return -1, false, errors.New("job is missing a destination repo")
+	}
+	// protect the 'pool' repo
+	if dst == repo.PoolName {
+		return -1, false, errors.New("'pool' is a reserved name and cannot be used for a new repo")
 	}
 	// Create a new job instance
 	j := &jobs.Job{
-		Type: jobs.Clone,
-		Src:  src,
-		Dst:  dst,
+		Type:     jobs.Clone,
+		Priority: priority,
+		Src:      src,
+		Dst:      dst,
 	}
 	// Add the job to the DB
 	return m.store.Push(j)
 }
 
 // CloneExecute carries out a clone job
-func (m *Manager) CloneExecute(j *jobs.Job) error {
+func (m *Manager) CloneExecute(ctx context.Context, j *jobs.Job) error {
+	// Validate the arguments
+	if len(j.Src) == 0 {
+		return errors.New("job is missing a source repo")
+	}
+	if len(j.Dst) == 0 {
+		return errors.New("job is missing a destination repo")
+	}
+	if j.Dst == repo.PoolName {
+		return errors.New("'pool' is a reserved name and cannot be used for a new repo")
+	}
+	// Never clone over the top of an existing repo
+	dst := &repo.Repo{
+		Name: j.Dst,
+	}
+	if _, err := os.Stat(dst.Path()); !os.IsNotExist(err) {
+		return fmt.Errorf("repo directory for '%s' already exists", j.Dst)
+	}
+	// The assets may have been put in place ahead of time, so only clean up what the clone adds
+	kept, err := listAssets(dst.AssetPath())
+	if err != nil {
+		return fmt.Errorf("failed to read the assets for '%s', reason: '%s'", j.Dst, err.Error())
+	}
+	// Begin a DB Transaction
+	tx, err := m.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to start DB transaction, reason: '%s'", err.Error())
+	}
+	var src *repo.Repo
+	var s repo.Summary
+	// Get the source Repo instance
+	if src, err = repo.Get(tx, j.Src); err != nil {
+		err = fmt.Errorf("failed to get the source Repo entry from the DB, reason: '%s'", err.Error())
+		goto ROLLBACK
+	}
 	// Create the new repo
-	if err := m.CreateExecute(j); err != nil {
-		return err
+	if err = dst.Create(tx); err != nil {
+		err = fmt.Errorf("failed to create repo entry in DB, reason: '%s'", err.Error())
+		goto ROLLBACK
+	}
+	if err = util.CreateDir(dst.Path()); err != nil {
+		goto ROLLBACK
+	}
+	// Fill it with the contents of the source
+	if s, err = repo.Clone(ctx, src, dst, j, tx); err != nil {
+		goto ROLLBACK
+	}
+	// Last chance to stop before making the changes permanent
+	if err = ctx.Err(); err != nil {
+		goto ROLLBACK
+	}
+	// End the transaction
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("failed to commit the transaction, reason: '%s'", err.Error())
+		goto CLEANUP
+	}
+	if err = dst.CommitDisk(); err != nil {
+		j.Log().Warnf("Failed to clean up after cloning '%s', reason: '%s'\n", dst.Name, err.Error())
+	}
+	// Save the summary into the job
+	if j.Results, err = s.MarshalBinary(); err != nil {
+		return fmt.Errorf("failed to convert Summary to binary for saving, reason: '%s'", err.Error())
+	}
+	return nil
+ROLLBACK:
+	tx.Rollback()
+CLEANUP:
+	// Nothing else can be using the new repo yet, so remove it entirely
+	os.RemoveAll(dst.Path())
+	removeAssets(dst.AssetPath(), kept)
+	return err
+}
+
+// listAssets gets the names of the files already in an asset directory, or nil if it doesn't exist yet
+func listAssets(path string) (names map[string]bool, err error) {
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	names = make(map[string]bool)
+	for _, file := range files {
+		names[file.Name()] = true
+	}
+	return
+}
+
+// removeAssets removes the files added to an asset directory since listAssets, and the directory
+// itself if it didn't exist before
+func removeAssets(path string, kept map[string]bool) {
+	if kept == nil {
+		os.RemoveAll(path)
+		return
+	}
+	files, _ := ioutil.ReadDir(path)
+	for _, file := range files {
+		if !kept[file.Name()] {
+			os.RemoveAll(filepath.Join(path, file.Name()))
+		}
 	}
-	// Sync from the existing repo
-	return m.SyncExecute(j)
 }
 
 // Compare reports on the differences between two repos
-func (m *Manager) Compare(left, right string) (int, error) {
+func (m *Manager) Compare(left, right string, priority jobs.JobPriority) (int, bool, error) {
 	// Validate the arguments
 	if len(left) == 0 {
-		return -1, errors.New("job is missing a left repo")
+		return -1, false, errors.New("job is missing a left repo")
 	}
 	if len(right) == 0 {
-		return -1, errors.New("job is missing a right repo")
+		return -1, false, errors.New("job is missing a right repo")
 	}
 	// Create a new job instance
 	j := &jobs.Job{
-		Type: jobs.Compare,
-		Src:  left,
-		Dst:  right,
+		Type:     jobs.Compare,
+		Priority: priority,
+		Src:      left,
+		Dst:      right,
 	}
 	// Add the job to the DB
 	return m.store.Push(j)
 }
 
 // CompareExecute carries out a Sync Job
-func (m *Manager) CompareExecute(j *jobs.Job) error {
-	return m.dualRepoExecute(repo.Compare, j)
+func (m *Manager) CompareExecute(ctx context.Context, j *jobs.Job) error {
+	return m.dualRepoExecute(ctx, repo.Compare, j)
 }
 
 // Sync compares two repos and makes changes so that "new" matches "old"
-func (m *Manager) Sync(src, dst string) (int, error) {
+func (m *Manager) Sync(src, dst string, priority jobs.JobPriority) (int, bool, error) {
 	// Validate the arguments
 	if len(src) == 0 {
-		return -1, errors.New("job is missing a source repo")
+		return -1, false, errors.New("job is missing a source repo")
 	}
 	if len(dst) == 0 {
-		return -1, errors.New("job is missing a destination repo")
+		return -1, false, errors.New("job is missing a destination repo")
 	}
 	// Create a new job instance
 	j := &jobs.Job{
-		Type: jobs.Sync,
-		Src:  src,
-		Dst:  dst,
+		Type:     jobs.Sync,
+		Priority: priority,
+		Src:      src,
+		Dst:      dst,
 	}
 	// Add the job to the DB
 	return m.store.Push(j)
 }
 
 // SyncExecute carries out a Sync job
-func (m *Manager) SyncExecute(j *jobs.Job) error {
-	return m.dualRepoExecute(repo.Sync, j)
+func (m *Manager) SyncExecute(ctx context.Context, j *jobs.Job) error {
+	return m.dualRepoExecute(ctx, repo.Sync, j)
 }
 
 // Repos provides a summary of all available repos
@@ -0,0 +1,60 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package manager
+
+import (
+	"github.com/mattn/go-sqlite3"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// transient are errors which are likely to clear up by themselves, so that the job can be retried
+var transient = []error{
+	sqlite3.ErrBusy,
+	sqlite3.ErrLocked,
+	syscall.EXDEV,
+	syscall.ENOSPC,
+}
+
+// retryable decides if a failed job is worth trying again, rather than failing permanently
+func retryable(err error) bool {
+	// Unwrap errors from the os package
+	switch e := err.(type) {
+	case *os.PathError:
+		err = e.Err
+	case *os.LinkError:
+		err = e.Err
+	case *os.SyscallError:
+		err = e.Err
+	case sqlite3.Error:
+		return e.Code == sqlite3.ErrBusy || e.Code == sqlite3.ErrLocked
+	}
+	for _, t := range transient {
+		if err == t {
+			return true
+		}
+	}
+	// Most errors are passed up as part of a message, so look for the original
+	msg := err.Error()
+	for _, t := range transient {
+		if strings.Contains(msg, t.Error()) {
+			return true
+		}
+	}
+	return false
+}
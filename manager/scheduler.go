@@ -0,0 +1,184 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package manager
+
+import (
+	"fmt"
+	log "github.com/DataDrake/waterlog"
+	"github.com/getsolus/ferryd/config"
+	"github.com/getsolus/ferryd/cron"
+	"github.com/getsolus/ferryd/jobs"
+	"sort"
+	"sync"
+	"time"
+)
+
+// scheduleActions are the Jobs which may be run on a schedule, by their ferryctl names
+var scheduleActions = map[string]jobs.JobType{
+	"check":          jobs.Check,
+	"delta":          jobs.Delta,
+	"index":          jobs.Index,
+	"rescan":         jobs.Rescan,
+	"trim-obsoletes": jobs.TrimObsoletes,
+	"trim-packages":  jobs.TrimPackages,
+}
+
+// Scheduled is a recurring Job and the next time it will be queued
+type Scheduled struct {
+	When string    `json:"when"`
+	Job  jobs.Job  `json:"job"`
+	Next time.Time `json:"next"`
+	spec *cron.Spec
+}
+
+// Scheduler periodically queues maintenance Jobs, like nightly Deltas
+type Scheduler struct {
+	sync.Mutex
+	manager *Manager
+	entries []*Scheduled
+	stop    chan bool
+	done    chan bool
+}
+
+// NewScheduler validates a list of Schedules and works out when each should first run
+func NewScheduler(mgr *Manager, schedules []config.Schedule) (s *Scheduler, err error) {
+	s = &Scheduler{
+		manager: mgr,
+		stop:    make(chan bool),
+		done:    make(chan bool),
+	}
+	now := time.Now()
+	for i, sched := range schedules {
+		// Validate the arguments
+		t, ok := scheduleActions[sched.Action]
+		if !ok {
+			return nil, fmt.Errorf("schedule %d has an invalid action '%s'", i, sched.Action)
+		}
+		if len(sched.Repo) == 0 {
+			return nil, fmt.Errorf("schedule %d is missing a repo", i)
+		}
+		if t == jobs.TrimPackages && sched.Max < 1 {
+			return nil, fmt.Errorf("schedule %d must keep at least 1 release", i)
+		}
+		spec, err := cron.Parse(sched.When)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %d is invalid, reason: '%s'", i, err.Error())
+		}
+		// Create the Job to queue
+		entry := &Scheduled{
+			When: sched.When,
+			Job: jobs.Job{
+				Type:     t,
+				Priority: jobs.DefaultPriority(t),
+				Src:      sched.Repo,
+				Max:      sched.Max,
+			},
+			Next: spec.Next(now),
+			spec: spec,
+		}
+		if entry.Next.IsZero() {
+			log.Warnf("Schedule %d will never run: '%s'\n", i, sched.When)
+		}
+		s.entries = append(s.entries, entry)
+	}
+	return
+}
+
+// Start creates a goroutine which will queue each Job when it is due
+func (s *Scheduler) Start() {
+	go func() {
+		for {
+			timer := time.NewTimer(s.untilNext())
+			select {
+			case now := <-timer.C:
+				s.run(now)
+			case <-s.stop:
+				timer.Stop()
+				s.done <- true
+				return
+			}
+		}
+	}()
+}
+
+// Stop waits for the Scheduler to shut down
+func (s *Scheduler) Stop() {
+	s.stop <- true
+	<-s.done
+}
+
+// untilNext works out how long to wait for the next Job to be due
+func (s *Scheduler) untilNext() time.Duration {
+	s.Lock()
+	defer s.Unlock()
+	// Nothing to do, check back tomorrow
+	wait := 24 * time.Hour
+	for _, entry := range s.entries {
+		if entry.Next.IsZero() {
+			continue
+		}
+		if d := time.Until(entry.Next); d < wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// run queues every Job that is due, unless an identical one is still waiting
+func (s *Scheduler) run(now time.Time) {
+	s.Lock()
+	defer s.Unlock()
+	for _, entry := range s.entries {
+		if entry.Next.IsZero() || entry.Next.After(now) {
+			continue
+		}
+		// Runs missed while the daemon was stopped or busy are not made up
+		entry.Next = entry.spec.Next(now)
+		j := entry.Job
+		id, err := s.manager.store.FindQueued(&j)
+		if err != nil {
+			log.Errorf("Failed to check for a queued job, reason: '%s'\n", err.Error())
+			continue
+		}
+		if id != 0 {
+			log.Infof("Skipping scheduled job, job %d is still queued: %s\n", id, j.Describe())
+			continue
+		}
+		if id, _, err = s.manager.store.Push(&j); err != nil {
+			log.Errorf("Failed to queue scheduled job, reason: '%s'\n", err.Error())
+			continue
+		}
+		log.Infof("Queued scheduled job %d: %s\n", id, j.Describe())
+	}
+}
+
+// Upcoming lists the scheduled Jobs, soonest first
+func (s *Scheduler) Upcoming() (list []Scheduled) {
+	s.Lock()
+	for _, entry := range s.entries {
+		list = append(list, *entry)
+	}
+	s.Unlock()
+	sort.SliceStable(list, func(i, j int) bool {
+		// Never comes last
+		if list[i].Next.IsZero() || list[j].Next.IsZero() {
+			return !list[i].Next.IsZero()
+		}
+		return list[i].Next.Before(list[j].Next)
+	})
+	return
+}
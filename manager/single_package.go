@@ -17,11 +17,13 @@
 package manager
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/getsolus/ferryd/jobs"
 	"github.com/getsolus/ferryd/manifest"
 	"github.com/getsolus/ferryd/repo"
+	"os"
 )
 
 /*********************/
@@ -29,22 +31,23 @@ import (
 /*********************/
 
 // TransitPackage processes an incoming package manifest, adding the package to "instant transit" repos
-func (m *Manager) TransitPackage(pkg string) (int, error) {
+func (m *Manager) TransitPackage(pkg string, priority jobs.JobPriority) (int, bool, error) {
 	// Check arguments
 	if len(pkg) == 0 {
-		return -1, errors.New("job is missing a package")
+		return -1, false, errors.New("job is missing a package")
 	}
 	// Create new job
 	j := &jobs.Job{
-		Type: jobs.TransitPackage,
-		Pkg:  pkg,
+		Type:     jobs.TransitPackage,
+		Priority: priority,
+		Pkg:      pkg,
 	}
 	// Add to the DB
 	return m.store.Push(j)
 }
 
 // TransitPackageExecute carries out a TransitPackage job
-func (m *Manager) TransitPackageExecute(j *jobs.Job) error {
+func (m *Manager) TransitPackageExecute(ctx context.Context, j *jobs.Job) error {
 	// Check arguments
 	if len(j.Pkg) == 0 {
 		return errors.New("job is missing a package")
@@ -72,7 +75,7 @@ func (m *Manager) TransitPackageExecute(j *jobs.Job) error {
 	// Find pool
 	var pool *repo.Repo
 	for _, r := range rs {
-		if r.Name == "pool" {
+		if r.IsPool() {
 			pool = r
 			break
 		}
@@ -87,14 +90,19 @@ func (m *Manager) TransitPackageExecute(j *jobs.Job) error {
 		tx.Rollback()
 		return fmt.Errorf("Failed to transit into the pool, reason: '%s'", err.Error())
 	}
+	// Once in the pool, the new packages must be linked, so this is the last chance to stop
+	if err = ctx.Err(); err != nil {
+		tx.Rollback()
+		return err
+	}
 	// End the transaction
 	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("Failed to end the transaction, reason: '%s'", err.Error())
 	}
 	// For each repo with instant_transit=true
 	for _, r := range rs {
-		// Skip pool
-		if r.Name == "pool" {
+		// Skip pool and any repos which must be updated manually
+		if r.IsPool() || !r.InstantTransit {
 			continue
 		}
 		// Create a DB transaction
@@ -104,17 +112,35 @@ func (m *Manager) TransitPackageExecute(j *jobs.Job) error {
 		}
 		// Copy in the new packages
 		if err = r.Link(tx, diff); err != nil {
+			tx.Rollback()
+			m.revertDisk(j, r)
 			return fmt.Errorf("Failed to link new packages, reason: '%s'", err.Error())
 		}
 		// Re-Index
-		if err = repo.Index(r, j, tx); err != nil {
+		if err = repo.Index(context.Background(), r, j, tx); err != nil {
 			tx.Rollback()
+			m.revertDisk(j, r)
 			return fmt.Errorf("Failed to reindex the repo '%s', reason: '%s'", r.Name, err.Error())
 		}
 		// End the transaction
 		if err = tx.Commit(); err != nil {
+			m.revertDisk(j, r)
 			return fmt.Errorf("Failed to end the transaction, reason: '%s'", err.Error())
 		}
+		// Keep the changes on disk
+		if err = r.CommitDisk(); err != nil {
+			j.Log().Warnf("Failed to clean up after changing '%s', reason: '%s'\n", r.Name, err.Error())
+		}
+	}
+	// The upload is now in every repo it belongs in, so clean up the transit directory. Until
+	// then, another attempt finds the packages already in the pool and only redoes the links.
+	for _, path := range manifest.GetPaths() {
+		if err = os.Remove(path); err != nil {
+			j.Log().Warnf("Failed to remove transited file '%s', reason: '%s'\n", path, err.Error())
+		}
+	}
+	if err = os.Remove(manifest.Path); err != nil {
+		j.Log().Warnf("Failed to remove transit manifest '%s', reason: '%s'\n", manifest.Path, err.Error())
 	}
 	return nil
 }
@@ -17,6 +17,7 @@
 package manager
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/getsolus/ferryd/config"
@@ -32,9 +33,9 @@ import (
 /* SINGLE REPO FUNCTIONS */
 /*************************/
 
-type singleRepoFunc func(r *repo.Repo, j *jobs.Job, tx *sqlx.Tx) error
+type singleRepoFunc func(ctx context.Context, r *repo.Repo, j *jobs.Job, tx *sqlx.Tx) error
 
-func (m *Manager) singleRepoExecute(single singleRepoFunc, j *jobs.Job) error {
+func (m *Manager) singleRepoExecute(ctx context.Context, single singleRepoFunc, j *jobs.Job) error {
 	// Validate the arguments
 	if len(j.Src) == 0 {
 		return errors.New("job is missing a source repo")
@@ -50,35 +51,69 @@ func (m *Manager) singleRepoExecute(single singleRepoFunc, j *jobs.Job) error {
 		tx.Rollback()
 		return fmt.Errorf("Failed to get the Repo entry from the DB, reason: '%s'", err.Error())
 	}
-	// Rescan the repo
-	if err = single(r, j, tx); err != nil {
+	// Carry out the action
+	if err = single(ctx, r, j, tx); err != nil {
 		tx.Rollback()
+		m.revertDisk(j, r)
+		return err
+	}
+	// Last chance to stop before making the changes permanent
+	if err = ctx.Err(); err != nil {
+		tx.Rollback()
+		m.revertDisk(j, r)
 		return err
 	}
 	// Save the transaction
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("Failed to remove the Repo from the DB, reason: '%s'", err.Error())
+		m.revertDisk(j, r)
+		return fmt.Errorf("Failed to commit the transaction, reason: '%s'", err.Error())
+	}
+	// Keep the changes on disk
+	if err = r.CommitDisk(); err != nil {
+		j.Log().Warnf("Failed to clean up after changing '%s', reason: '%s'\n", r.Name, err.Error())
+	}
+	return nil
+}
+
+type singleDiffFunc func(ctx context.Context, r *repo.Repo, j *jobs.Job, tx *sqlx.Tx) (d *repo.Diff, err error)
+
+// singleDiffExecute carries out an action on a single repo which generates a Diff
+func (m *Manager) singleDiffExecute(ctx context.Context, single singleDiffFunc, j *jobs.Job) error {
+	var diff *repo.Diff
+	// Carry out the action, keeping hold of the Diff
+	err := m.singleRepoExecute(ctx, func(ctx context.Context, r *repo.Repo, j *jobs.Job, tx *sqlx.Tx) (err error) {
+		diff, err = single(ctx, r, j, tx)
+		return
+	}, j)
+	if err != nil {
+		return err
+	}
+	// Save the diff into the job
+	j.Results, err = diff.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("Failed to convert Diff to binary for saving, reason: '%s'", err.Error())
 	}
 	return nil
 }
 
 // Check compares an existing repo on Disk with its DB
-func (m *Manager) Check(name string) (int, error) {
+func (m *Manager) Check(name string, priority jobs.JobPriority) (int, bool, error) {
 	// Validate the job arguments
 	if len(name) == 0 {
-		return -1, errors.New("job is missing a source repo")
+		return -1, false, errors.New("job is missing a source repo")
 	}
 	// Create the job
 	j := &jobs.Job{
-		Type: jobs.Check,
-		Src:  name,
+		Type:     jobs.Check,
+		Priority: priority,
+		Src:      name,
 	}
 	// Add the job to the DB
 	return m.store.Push(j)
 }
 
 // CheckExecute carries out a Check job
-func (m *Manager) CheckExecute(j *jobs.Job) error {
+func (m *Manager) CheckExecute(ctx context.Context, j *jobs.Job) error {
 	var d *repo.Diff
 	// Validate arguments
 	if len(j.Src) == 0 {
@@ -95,7 +130,7 @@ func (m *Manager) CheckExecute(j *jobs.Job) error {
 		goto ROLLBACK
 	}
 	// Run the check
-	d, err = r.Check(tx)
+	d, err = r.Check(tx, j)
 	if err != nil {
 		goto ROLLBACK
 	}
@@ -111,10 +146,14 @@ ROLLBACK:
 }
 
 // Create sets up a new repo
-func (m *Manager) Create(name string, instant bool) (int, error) {
+func (m *Manager) Create(name string, instant bool, priority jobs.JobPriority) (int, bool, error) {
 	// Validate the job arguments
 	if len(name) == 0 {
-		return -1, errors.New("job is missing a destination repo")
+		return -1, false, errors.New("job is missing a destination repo")
+	}
+	// protect the 'pool' repo
+	if name == repo.PoolName {
+		return -1, false, errors.New("'pool' is a reserved name and cannot be used for a new repo")
 	}
 	// Create a new job
 	max := 0
@@ -122,24 +161,21 @@ func (m *Manager) Create(name string, instant bool) (int, error) {
 		max = 1
 	}
 	j := &jobs.Job{
-		Type: jobs.Create,
-		Dst:  name,
-		Max:  max,
+		Type:     jobs.Create,
+		Priority: priority,
+		Dst:      name,
+		Max:      max,
 	}
 	// Add it to the DB
 	return m.store.Push(j)
 }
 
 // CreateExecute carries out a Create job
-func (m *Manager) CreateExecute(j *jobs.Job) error {
+func (m *Manager) CreateExecute(ctx context.Context, j *jobs.Job) error {
 	// Validate the job arguments
 	if len(j.Dst) == 0 {
 		return errors.New("job is missing a destination repo")
 	}
-	// protect the 'pool' repo
-	if j.Dst == "pool" {
-		return errors.New("'pool' is a reserved name and cannot be used for a new repo")
-	}
 	// Create the repo directory
 	rp := filepath.Join(config.Current.RepoPath(), j.Dst)
 	if err := util.CreateDir(rp); err != nil {
@@ -150,11 +186,12 @@ func (m *Manager) CreateExecute(j *jobs.Job) error {
 	if err := util.CreateDir(ap); err != nil {
 		return err
 	}
-	// Create the assets directory
-	assetsDir := filepath.Join(config.Current.AssetPath(), j.Dst)
-	poolAssets := filepath.Join(config.Current.AssetPath(), "pool")
-	if err := util.CopyDir(poolAssets, assetsDir, false); err != nil {
-		return fmt.Errorf("Failed to create assets dir, reason: '%s'", err.Error())
+	// Copy in the default assets from the pool
+	if j.Dst != repo.PoolName {
+		poolAssets := filepath.Join(config.Current.AssetPath(), repo.PoolName)
+		if err := util.CopyDir(poolAssets, ap, false); err != nil {
+			return fmt.Errorf("Failed to create assets dir, reason: '%s'", err.Error())
+		}
 	}
 	// Add the repo to the DB
 	// Create a DB transaction
@@ -173,34 +210,42 @@ func (m *Manager) CreateExecute(j *jobs.Job) error {
 		return fmt.Errorf("Failed to create repo entry in DB, reason: '%s'", err.Error())
 	}
 	// End the transaction
-	return tx.Commit()
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	// Transits may need to link into the new repo
+	if r.InstantTransit {
+		return m.updateInstantTransit()
+	}
+	return nil
 }
 
 // Delta generates missing package deltas for an entire repo
-func (m *Manager) Delta(name string) (int, error) {
+func (m *Manager) Delta(name string, priority jobs.JobPriority) (int, bool, error) {
 	// Validate the arguments
 	if len(name) == 0 {
-		return -1, errors.New("job is missing a source repo")
+		return -1, false, errors.New("job is missing a source repo")
 	}
 	// Create a new job instance
 	j := &jobs.Job{
-		Type: jobs.Delta,
-		Src:  name,
+		Type:     jobs.Delta,
+		Priority: priority,
+		Src:      name,
 	}
 	// Add the job to the DB
 	return m.store.Push(j)
 }
 
 // DeltaExecute carries out a Delta job
-func (m *Manager) DeltaExecute(j *jobs.Job) error {
-	return m.singleRepoExecute(repo.Delta, j)
+func (m *Manager) DeltaExecute(ctx context.Context, j *jobs.Job) error {
+	return m.singleDiffExecute(ctx, repo.Delta, j)
 }
 
 // Import adds an existing repo to the database
-func (m *Manager) Import(name string, instant bool) (int, error) {
+func (m *Manager) Import(name string, instant bool, priority jobs.JobPriority) (int, bool, error) {
 	// Validate the arguments
 	if len(name) == 0 {
-		return -1, errors.New("job is missing a source repo")
+		return -1, false, errors.New("job is missing a source repo")
 	}
 	// Create a new job instance
 	max := 0
@@ -208,25 +253,26 @@ func (m *Manager) Import(name string, instant bool) (int, error) {
 		max = 1
 	}
 	j := &jobs.Job{
-		Type: jobs.Import,
-		Src:  name,
-		Max:  max,
+		Type:     jobs.Import,
+		Priority: priority,
+		Src:      name,
+		Max:      max,
 	}
 	// Insert the new job into the DB
 	return m.store.Push(j)
 }
 
 // ImportExecute carries out an Import job
-func (m *Manager) ImportExecute(j *jobs.Job) error {
+func (m *Manager) ImportExecute(ctx context.Context, j *jobs.Job) error {
 	// Validate the job arguments
-	if len(j.Dst) == 0 {
-		return errors.New("job is missing a destination repo")
+	if len(j.Src) == 0 {
+		return errors.New("job is missing a source repo")
 	}
-	// Create the repo directory
-	repoDir := filepath.Join(config.Current.RepoPath(), j.Dst)
+	// Check for the repo directory
+	repoDir := filepath.Join(config.Current.RepoPath(), j.Src)
 	if _, err := os.Stat(repoDir); err != nil {
 		if os.IsNotExist(err) {
-			return fmt.Errorf("repo directory for '%s' does not exist", j.Dst)
+			return fmt.Errorf("repo directory for '%s' does not exist", j.Src)
 		}
 		return err
 	}
@@ -238,7 +284,7 @@ func (m *Manager) ImportExecute(j *jobs.Job) error {
 	}
 	// Create a new repo object
 	r := &repo.Repo{
-		Name:           j.Dst,
+		Name:           j.Src,
 		InstantTransit: j.Max == 1,
 	}
 	// Insert into the DB
@@ -250,114 +296,129 @@ func (m *Manager) ImportExecute(j *jobs.Job) error {
 	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("Failed to create repo entry in DB, reason: '%s'", err.Error())
 	}
+	// Transits may need to link into the new repo
+	if r.InstantTransit {
+		if err = m.updateInstantTransit(); err != nil {
+			return err
+		}
+	}
 	// Scan and add all of the package to the DB
-	return m.RescanExecute(j)
+	return m.RescanExecute(ctx, j)
 }
 
 // Index generates a new package index
-func (m *Manager) Index(name string) (int, error) {
+func (m *Manager) Index(name string, priority jobs.JobPriority) (int, bool, error) {
 	// Validating the arguments
 	if len(name) == 0 {
-		return -1, errors.New("job is missing a source repo")
+		return -1, false, errors.New("job is missing a source repo")
 	}
 	// Create a new job instance
 	j := &jobs.Job{
-		Type: jobs.Index,
-		Src:  name,
+		Type:     jobs.Index,
+		Priority: priority,
+		Src:      name,
 	}
 	// Add job to the DB
 	return m.store.Push(j)
 }
 
 // IndexExecute carries out an Index job
-func (m *Manager) IndexExecute(j *jobs.Job) error {
-	return m.singleRepoExecute(repo.Index, j)
+func (m *Manager) IndexExecute(ctx context.Context, j *jobs.Job) error {
+	return m.singleRepoExecute(ctx, repo.Index, j)
 }
 
 // Remove deletes a repo from the DB
-func (m *Manager) Remove(name string) (int, error) {
+func (m *Manager) Remove(name string, priority jobs.JobPriority) (int, bool, error) {
 	// Validate the arguments
 	if len(name) == 0 {
-		return -1, errors.New("job is missing a source repo")
+		return -1, false, errors.New("job is missing a source repo")
 	}
 	// Create a new job instance
 	j := &jobs.Job{
-		Type: jobs.Remove,
-		Src:  name,
+		Type:     jobs.Remove,
+		Priority: priority,
+		Src:      name,
 	}
 	// Add the job to the DB
 	return m.store.Push(j)
 }
 
 // RemoveExecute carries out a Remove job
-func (m *Manager) RemoveExecute(j *jobs.Job) error {
-	return m.singleRepoExecute(repo.Remove, j)
+func (m *Manager) RemoveExecute(ctx context.Context, j *jobs.Job) error {
+	if err := m.singleRepoExecute(ctx, repo.Remove, j); err != nil {
+		return err
+	}
+	// Transits no longer need to wait for the removed repo
+	return m.updateInstantTransit()
 }
 
 // Rescan rebuild the database for an existing repo
-func (m *Manager) Rescan(name string) (int, error) {
+func (m *Manager) Rescan(name string, priority jobs.JobPriority) (int, bool, error) {
 	// Validate the arguments
 	if len(name) == 0 {
-		return -1, errors.New("job is missing a source repo")
+		return -1, false, errors.New("job is missing a source repo")
 	}
 	// Create a new job instance
 	j := &jobs.Job{
-		Type: jobs.Rescan,
-		Src:  name,
+		Type:     jobs.Rescan,
+		Priority: priority,
+		Src:      name,
 	}
 	// Add the job to the DB
 	return m.store.Push(j)
 }
 
 // RescanExecute carries out a Rescan job
-func (m *Manager) RescanExecute(j *jobs.Job) error {
-	return m.singleRepoExecute(repo.Rescan, j)
+func (m *Manager) RescanExecute(ctx context.Context, j *jobs.Job) error {
+	return m.singleDiffExecute(ctx, repo.Rescan, j)
 }
 
 // TrimObsoletes removes obsolete packages and their deltas
-func (m *Manager) TrimObsoletes(name string) (int, error) {
+func (m *Manager) TrimObsoletes(name string, priority jobs.JobPriority) (int, bool, error) {
 	// Validate the arguments
 	if len(name) == 0 {
-		return -1, errors.New("job is missing a source repo")
+		return -1, false, errors.New("job is missing a source repo")
 	}
 	// Create a new job instance
 	j := &jobs.Job{
-		Type: jobs.TrimObsoletes,
-		Src:  name,
+		Type:     jobs.TrimObsoletes,
+		Priority: priority,
+		Src:      name,
 	}
 	// Add the job to the DB
 	return m.store.Push(j)
 }
 
 // TrimObsoletesExecute carries out the TrimObsoletes job
-func (m *Manager) TrimObsoletesExecute(j *jobs.Job) error {
-	return m.singleRepoExecute(repo.TrimObsolete, j)
+func (m *Manager) TrimObsoletesExecute(ctx context.Context, j *jobs.Job) error {
+	return m.singleDiffExecute(ctx, repo.TrimObsolete, j)
 }
 
 // TrimPackages removes old package releases and their deltas
-func (m *Manager) TrimPackages(name string, max int) (int, error) {
+func (m *Manager) TrimPackages(name string, max int, priority jobs.JobPriority) (int, bool, error) {
 	// Validate the arguments
 	if len(name) == 0 {
-		return -1, errors.New("job is missing a source repo")
+		return -1, false, errors.New("job is missing a source repo")
 	}
 	if max < 1 {
-		return -1, errors.New("max releases must be at least 1")
+		return -1, false, errors.New("max releases must be at least 1")
 	}
 	// Create a new job instance
 	j := &jobs.Job{
-		Type: jobs.TrimPackages,
-		Src:  name,
-		Max:  max,
+		Type:     jobs.TrimPackages,
+		Priority: priority,
+		Src:      name,
+		Max:      max,
 	}
 	// Add the job to the DB
 	return m.store.Push(j)
 }
 
 // TrimPackagesExecute carries out a TrimPackages job
-func (m *Manager) TrimPackagesExecute(j *jobs.Job) error {
+func (m *Manager) TrimPackagesExecute(ctx context.Context, j *jobs.Job) error {
 	// Validate the arguments
 	if j.Max < 1 {
 		return errors.New("max releases must be at least 1")
 	}
-	return m.singleRepoExecute(repo.TrimPackages, j)
+	return m.singleDiffExecute(ctx, repo.TrimPackages, j)
 }
@@ -17,21 +17,24 @@
 package manager
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	log "github.com/DataDrake/waterlog"
 	"github.com/getsolus/ferryd/jobs"
 	"math/rand"
 	"time"
 )
 
-// MinWait is the minimum amount of time between retries for a worker
-const MinWait = time.Second * 2
+// MinWait is the minimum amount of time between retries for a worker. Workers are woken
+// as soon as a Job may be claimable, so this is only a fallback for Jobs waiting to be retried.
+const MinWait = time.Second * 10
 
 // MaxJitter sets the upper limit on the random jitter used for retry times
 const MaxJitter int64 = 512
 
 // A Worker is used to execute some portion of the incoming workload, and will
-// keep polling for the correct job type to process
+// keep waiting for the correct job type to process
 type Worker struct {
 	timer   *time.Timer
 	manager *Manager
@@ -52,49 +55,55 @@ func NewWorker(manager *Manager) *Worker {
 	}
 }
 
-// Stop will demand that all new requests are no longer processed
+// Stop will demand that all new requests are no longer processed, waiting
+// for any job which is still running to finish. Only for a started Worker.
 func (w *Worker) Stop() {
-	if w.timer == nil {
-		return
-	}
 	w.stop <- true
-	w.timer.Stop()
 	<-w.done
+	w.timer.Stop()
 }
 
-// Start will begin the main execution of this worker, and will continuously
-// poll for new jobs with an increasing increment (with a ceiling limit)
+// Start will begin the main execution of this worker, which runs jobs for as long
+// as they are available, and then waits until the Store says another may be ready
 func (w *Worker) Start() {
 	// Let's get our timer initialised
 	w.setTime()
 
 	for {
+		// Watch for new jobs before looking, so that none are missed
+		ready := w.manager.store.Ready()
+		// Try to grab a job
+		job, ctx, err := w.manager.store.Claim()
+		if err == nil {
+			// Got a job, now process it
+			w.processJob(ctx, job)
+			// Mark the job as dealt with, reporting any failure
+			if err := w.manager.store.Retire(job); err != nil {
+				log.Errorf("Error in retiring job '%v' of type '%v', reason: '%s'\n", job.ID, job.Type, err.Error())
+			}
+		} else if err != jobs.ErrNoJobReady {
+			// Report the error
+			log.Errorf("Failed to grab a work queue item, reason: '%s'\n", err.Error())
+		}
+		// We must reset the timeout period
+		w.setTime()
+		// Look for the next job right away, unless we've been told to go home
+		if err == nil {
+			select {
+			case <-w.stop:
+				w.done <- true
+				return
+			default:
+				continue
+			}
+		}
 		select {
 		case <-w.stop:
 			// Bail now, we've been told to go home
 			w.done <- true
 			return
+		case <-ready:
 		case <-w.timer.C:
-			// Try to grab a job
-			job, err := w.manager.store.Claim()
-			// Report the error
-			if err != nil {
-				if err != jobs.ErrNoJobReady {
-					log.Errorf("Failed to grab a work queue item, reason: '%s'\n", err.Error())
-				}
-				w.setTime()
-				continue
-			}
-			// Got a job, now process it
-			w.processJob(job)
-			// Mark the job as dealt with
-			err = w.manager.store.Retire(job)
-			// Report failure in retiring the job
-			if err != nil {
-				log.Errorf("Error in retiring job '%v' of type '%v', reason: '%s'\n", job.ID, job.Type, err.Error())
-			}
-			// We had a job, so we must reset the timeout period
-			w.setTime()
 		}
 	}
 }
@@ -104,57 +113,85 @@ func (w *Worker) setTime() {
 	delay := MinWait + (time.Millisecond * time.Duration(rand.Int63n(MaxJitter)))
 	if w.timer == nil {
 		w.timer = time.NewTimer(delay)
-	} else {
-		w.timer.Reset(delay)
+		return
+	}
+	// Make sure an old timeout doesn't wake us early
+	if !w.timer.Stop() {
+		select {
+		case <-w.timer.C:
+		default:
+		}
 	}
+	w.timer.Reset(delay)
 }
 
 // processJob will actually examine the given job and figure out how
 // to execute it. Each Worker can only execute a single job at a time
-func (w *Worker) processJob(j *jobs.Job) {
+func (w *Worker) processJob(ctx context.Context, j *jobs.Job) {
 	// Safely have a handler now
 	j.Message.String = j.Describe()
 	// Try to execute it, report the error
-	if err := w.executeJob(j); err != nil {
+	if err := w.executeJob(ctx, j); err != nil {
+		// Cancelled jobs stop with an error, after undoing their changes
+		if ctx.Err() != nil {
+			j.Status = jobs.Cancelled
+			j.Message.String = "Cancelled during execution"
+			j.Message.Valid = true
+			j.Log().Infof("Cancelled during execution\n")
+			return
+		}
+		// Try again later if the problem is likely to go away by itself
+		if retryable(err) && j.Attempts < j.MaxAttempts {
+			delay := jobs.Backoff(j.Attempts)
+			j.Status = jobs.New
+			j.NotBefore.Time = time.Now().UTC().Add(delay)
+			j.NotBefore.Valid = true
+			j.Message.String = fmt.Sprintf("Attempt %d of %d failed, reason: '%s'", j.Attempts, j.MaxAttempts, err.Error())
+			j.Message.Valid = true
+			j.Log().Warnf("Failed with error: '%s', retrying in %s\n", err.Error(), delay)
+			return
+		}
 		j.Status = jobs.Failed
 		j.Message.String = err.Error()
 		j.Message.Valid = true
-		log.Errorf("Job '%d' failed with error: '%s'\n", j.ID, err.Error())
+		j.Log().Errorf("Failed with error: '%s'\n", err.Error())
 		return
 	}
 	j.Status = jobs.Completed
 	// Succeeded
-	log.Infof("Job '%d' completed successfully\n", j.ID)
+	j.Log().Infof("Completed successfully\n")
 }
 
-func (w *Worker) executeJob(j *jobs.Job) error {
+func (w *Worker) executeJob(ctx context.Context, j *jobs.Job) error {
 	switch j.Type {
 	case jobs.Check:
-		return w.manager.CheckExecute(j)
+		return w.manager.CheckExecute(ctx, j)
 	case jobs.CherryPick:
-		return w.manager.CherryPickExecute(j)
+		return w.manager.CherryPickExecute(ctx, j)
 	case jobs.Clone:
-		return w.manager.CloneExecute(j)
+		return w.manager.CloneExecute(ctx, j)
 	case jobs.Compare:
-		return w.manager.CompareExecute(j)
+		return w.manager.CompareExecute(ctx, j)
 	case jobs.Create:
-		return w.manager.CreateExecute(j)
+		return w.manager.CreateExecute(ctx, j)
 	case jobs.Delta:
-		return w.manager.DeltaExecute(j)
+		return w.manager.DeltaExecute(ctx, j)
 	case jobs.Import:
-		return w.manager.ImportExecute(j)
+		return w.manager.ImportExecute(ctx, j)
 	case jobs.Index:
-		return w.manager.IndexExecute(j)
+		return w.manager.IndexExecute(ctx, j)
 	case jobs.Remove:
-		return w.manager.RemoveExecute(j)
+		return w.manager.RemoveExecute(ctx, j)
 	case jobs.Rescan:
-		return w.manager.RescanExecute(j)
+		return w.manager.RescanExecute(ctx, j)
 	case jobs.Sync:
-		return w.manager.SyncExecute(j)
+		return w.manager.SyncExecute(ctx, j)
+	case jobs.TransitPackage:
+		return w.manager.TransitPackageExecute(ctx, j)
 	case jobs.TrimObsoletes:
-		return w.manager.TrimObsoletesExecute(j)
+		return w.manager.TrimObsoletesExecute(ctx, j)
 	case jobs.TrimPackages:
-		return w.manager.TrimPackagesExecute(j)
+		return w.manager.TrimPackagesExecute(ctx, j)
 	default:
 		return errors.New("Unsupported Job Type")
 	}
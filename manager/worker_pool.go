@@ -17,32 +17,45 @@
 package manager
 
 import (
+	"fmt"
 	log "github.com/DataDrake/waterlog"
+	"github.com/getsolus/ferryd/config"
 	"runtime"
+	"sync"
 )
 
 // A Pool is responsible for the main dispatch and bulking of jobs
 // to ensure they're handled in the most optimal fashion.
 type Pool struct {
+	sync.Mutex
+	manager *Manager
 	closed  bool
-	njobs   int
+	started bool
 	workers []*Worker
+	// stopping tracks removed workers which are finishing their last job
+	stopping sync.WaitGroup
 }
 
-// NewPool will return a new Pool with the specified number
+// DefaultWorkers is half of the system core count, because we use xz -T 2 (so twice the number of threads ..)
+func DefaultWorkers() int {
+	if n := runtime.NumCPU() / 2; n > 0 {
+		return n
+	}
+	return 1
+}
+
+// NewPool will return a new Pool with the configured number
 // of jobs. Note that "njobs" only refers to the number of *background jobs*,
 // the majority of operations will run sequentially
 func NewPool(manager *Manager) *Pool {
-	// If we set to -1, we'll automatically set to half of the system core count
-	// because we use xz -T 2 (so twice the number of threads ..)
-	njobs := -1
-	if njobs < 0 {
-		njobs = runtime.NumCPU() / 2
+	njobs := config.Current.Workers
+	if njobs <= 0 {
+		njobs = DefaultWorkers()
 	}
 	log.Infof("Set runtime job limit: %d\n", njobs)
 	ret := &Pool{
-		closed: false,
-		njobs:  njobs,
+		manager: manager,
+		closed:  false,
 	}
 	// Construct worker pool
 	for i := 0; i < njobs; i++ {
@@ -51,24 +64,75 @@ func NewPool(manager *Manager) *Pool {
 	return ret
 }
 
+// Size gets the current number of workers
+func (j *Pool) Size() int {
+	j.Lock()
+	defer j.Unlock()
+	return len(j.workers)
+}
+
+// Resize adds or removes workers until there are "njobs" of them. Removed workers
+// finish any job they are running in the background.
+func (j *Pool) Resize(njobs int) error {
+	if njobs < 1 {
+		return fmt.Errorf("invalid number of workers '%d', must be at least 1", njobs)
+	}
+	j.Lock()
+	defer j.Unlock()
+	if j.closed {
+		return fmt.Errorf("the worker pool has been closed")
+	}
+	// Add new workers
+	for len(j.workers) < njobs {
+		w := NewWorker(j.manager)
+		j.workers = append(j.workers, w)
+		if j.started {
+			go w.Start()
+		}
+	}
+	// Remove the newest workers
+	for len(j.workers) > njobs {
+		w := j.workers[len(j.workers)-1]
+		j.workers = j.workers[:len(j.workers)-1]
+		if j.started {
+			j.stopping.Add(1)
+			go func() {
+				w.Stop()
+				j.stopping.Done()
+			}()
+		}
+	}
+	log.Infof("Set runtime job limit: %d\n", njobs)
+	return nil
+}
+
 // Close an existing Pool, waiting for all jobs to complete
 func (j *Pool) Close() {
+	j.Lock()
+	defer j.Unlock()
 	if j.closed {
 		return
 	}
 	// Close all of our workers
-	for _, j := range j.workers {
-		j.Stop()
+	if j.started {
+		for _, w := range j.workers {
+			w.Stop()
+		}
 	}
+	// Wait for any removed workers too
+	j.stopping.Wait()
 	j.closed = true
 }
 
 // Begin will start the main job pool in parallel
 func (j *Pool) Begin() {
-	if j.closed {
+	j.Lock()
+	defer j.Unlock()
+	if j.closed || j.started {
 		return
 	}
-	for _, j := range j.workers {
-		go j.Start()
+	for _, w := range j.workers {
+		go w.Start()
 	}
+	j.started = true
 }
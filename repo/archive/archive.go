@@ -17,8 +17,10 @@
 package archive
 
 import (
+	"encoding/xml"
 	"errors"
 	"fmt"
+	eopkg "github.com/getsolus/libeopkg/archive"
 	"github.com/jmoiron/sqlx"
 	"io"
 	"path/filepath"
@@ -37,8 +39,32 @@ const (
 	StatusModified
 	// StatusRemoved indicates that this Archive should be removed from the target Repo
 	StatusRemoved
+	// StatusMissing indicates that this Archive is in the DB but not on disk
+	StatusMissing
+	// StatusUntracked indicates that this Archive is on disk but not in the DB
+	StatusUntracked
+	// StatusSizeMismatch indicates that the size of this Archive on disk does not match the DB
+	StatusSizeMismatch
+	// StatusHashMismatch indicates that the hash of this Archive on disk does not match the DB
+	StatusHashMismatch
 )
 
+var statusMap = map[Status]string{
+	StatusUnchanged:    "unchanged",
+	StatusAdded:        "added",
+	StatusModified:     "modified",
+	StatusRemoved:      "removed",
+	StatusMissing:      "missing",
+	StatusUntracked:    "untracked",
+	StatusSizeMismatch: "size mismatch",
+	StatusHashMismatch: "hash mismatch",
+}
+
+// String returns a human-readable version of a Status
+func (s Status) String() string {
+	return statusMap[s]
+}
+
 var (
 	// ErrInvalidArchive indicates that the relevant Archive DB entry is malformed
 	ErrInvalidArchive = errors.New("invalid archive")
@@ -79,6 +105,24 @@ func (a *Archive) Name() (name string, err error) {
 	return
 }
 
+// Metadata decodes the eopkg metadata stored for this Archive
+func (a *Archive) Metadata() (m *eopkg.Metadata, err error) {
+	if len(a.Meta) == 0 {
+		err = ErrInvalidArchive
+		return
+	}
+	m = &eopkg.Metadata{}
+	if err = xml.Unmarshal(a.Meta, m); err != nil {
+		m = nil
+		return
+	}
+	if m.Package == nil {
+		m = nil
+		err = ErrInvalidArchive
+	}
+	return
+}
+
 // IsPackage checks if this is a valid Package Archive
 func (a *Archive) IsPackage() bool {
 	return a.Release > 0 && a.To == 0
@@ -138,12 +182,19 @@ func (a *Archive) Save(tx *sqlx.Tx) (err error) {
 	return
 }
 
+// Remove deletes the record for this Archive
+func (a *Archive) Remove(tx *sqlx.Tx) error {
+	_, err := tx.NamedExec(Remove, a)
+	return err
+}
+
 // PrintDiff prints an Archive according to its Status
 func (a *Archive) PrintDiff(out io.Writer, plus, minus, mod, same string) error {
-	name, err := a.Name()
-	if err != nil {
-		return err
+	// Problems found on disk may not belong to a valid Archive
+	if len(a.URI) == 0 {
+		return ErrInvalidArchive
 	}
+	name := filepath.Base(a.URI)
 	switch a.Status {
 	case StatusAdded:
 		fmt.Fprintf(out, plus, name)
@@ -151,6 +202,12 @@ func (a *Archive) PrintDiff(out io.Writer, plus, minus, mod, same string) error
 		fmt.Fprintf(out, minus, name)
 	case StatusModified:
 		fmt.Fprintf(out, mod, name)
+	case StatusMissing:
+		fmt.Fprintf(out, minus, name+" ("+a.Status.String()+")")
+	case StatusUntracked:
+		fmt.Fprintf(out, plus, name+" ("+a.Status.String()+")")
+	case StatusSizeMismatch, StatusHashMismatch:
+		fmt.Fprintf(out, mod, name+" ("+a.Status.String()+")")
 	default:
 		fmt.Fprintf(out, same, name)
 	}
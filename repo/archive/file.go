@@ -0,0 +1,129 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package archive
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/getsolus/ferryd/core"
+	eopkg "github.com/getsolus/libeopkg/archive"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PackageSuffix is the file extension of all Archives
+	PackageSuffix = ".eopkg"
+	// DeltaSuffix is the file extension of Delta Archives
+	DeltaSuffix = ".delta.eopkg"
+)
+
+// IsArchiveFile checks if a filename belongs to a Package or Delta Archive
+func IsArchiveFile(name string) bool {
+	return strings.HasSuffix(name, PackageSuffix)
+}
+
+// IsDeltaFile checks if a filename belongs to a Delta Archive
+func IsDeltaFile(name string) bool {
+	return strings.HasSuffix(name, DeltaSuffix)
+}
+
+// ParseFilename fills in the Package, Release and To fields of an Archive using only its filename
+//
+// Packages are named "name-version-release-distrel-arch.eopkg"
+// Deltas are named "name-from-to-distrel-arch.delta.eopkg"
+func ParseFilename(uri string) (a *Archive, err error) {
+	name := filepath.Base(uri)
+	a = &Archive{
+		URI: uri,
+	}
+	delta := IsDeltaFile(name)
+	if delta {
+		name = strings.TrimSuffix(name, DeltaSuffix)
+	} else {
+		name = strings.TrimSuffix(name, PackageSuffix)
+	}
+	pieces := strings.Split(name, "-")
+	if len(pieces) < 5 {
+		return nil, fmt.Errorf("malformed archive filename '%s'", filepath.Base(uri))
+	}
+	a.Package = strings.Join(pieces[:len(pieces)-4], "-")
+	if delta {
+		if a.Release, err = strconv.Atoi(pieces[len(pieces)-4]); err != nil {
+			return nil, fmt.Errorf("malformed delta release in '%s'", filepath.Base(uri))
+		}
+		if a.To, err = strconv.Atoi(pieces[len(pieces)-3]); err != nil {
+			return nil, fmt.Errorf("malformed delta release in '%s'", filepath.Base(uri))
+		}
+	} else {
+		if a.Release, err = strconv.Atoi(pieces[len(pieces)-3]); err != nil {
+			return nil, fmt.Errorf("malformed package release in '%s'", filepath.Base(uri))
+		}
+	}
+	if !a.IsValid() {
+		return nil, ErrInvalidArchive
+	}
+	return
+}
+
+// Open reads an Archive from an .eopkg file on disk, where "uri" is its location relative to the repo
+func Open(path, uri string) (a *Archive, err error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	hash, err := core.FileSHA1Sum(path)
+	if err != nil {
+		return
+	}
+	// Read in the eopkg metadata
+	pkg, err := eopkg.Open(path)
+	if err != nil {
+		return
+	}
+	defer pkg.Close()
+	if err = pkg.ReadMetadata(); err != nil {
+		return
+	}
+	meta, err := xml.Marshal(pkg.Meta)
+	if err != nil {
+		return
+	}
+	a = &Archive{
+		Package: pkg.Meta.Package.Name,
+		URI:     uri,
+		Size:    int(st.Size()),
+		Hash:    hash,
+		Release: pkg.Meta.Package.GetRelease(),
+		Meta:    meta,
+	}
+	// Deltas contain the metadata of the release they upgrade to
+	if IsDeltaFile(path) {
+		var parsed *Archive
+		if parsed, err = ParseFilename(uri); err != nil {
+			return nil, err
+		}
+		a.To = a.Release
+		a.Release = parsed.Release
+	}
+	if !a.IsValid() {
+		return nil, ErrInvalidArchive
+	}
+	return
+}
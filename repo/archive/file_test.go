@@ -0,0 +1,53 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package archive
+
+import (
+	"testing"
+)
+
+func TestParseFilename(t *testing.T) {
+	a, err := ParseFilename("n/nano/nano-dbginfo-4.5-116-1-x86_64.eopkg")
+	if err != nil {
+		t.Fatalf("Failed to parse valid package filename: %v", err)
+	}
+	if a.Package != "nano-dbginfo" {
+		t.Fatalf("Invalid package name: %s", a.Package)
+	}
+	if a.Release != 116 || a.To != 0 {
+		t.Fatalf("Invalid package releases: %d -> %d", a.Release, a.To)
+	}
+	if !a.IsPackage() {
+		t.Fatal("Package filename was not parsed as a package")
+	}
+	a, err = ParseFilename("n/nano/nano-116-118-1-x86_64.delta.eopkg")
+	if err != nil {
+		t.Fatalf("Failed to parse valid delta filename: %v", err)
+	}
+	if a.Package != "nano" {
+		t.Fatalf("Invalid delta name: %s", a.Package)
+	}
+	if a.Release != 116 || a.To != 118 {
+		t.Fatalf("Invalid delta releases: %d -> %d", a.Release, a.To)
+	}
+	if !a.IsDelta() {
+		t.Fatal("Delta filename was not parsed as a delta")
+	}
+	if _, err = ParseFilename("n/nano/nano.eopkg"); err == nil {
+		t.Fatal("Parsed a malformed filename without error")
+	}
+}
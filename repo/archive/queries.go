@@ -25,30 +25,62 @@ CREATE TABLE IF NOT EXISTS archives (
     size         INTEGER,
     hash         TEXT,
     release      INTEGER,
-    from_release INTEGER,
+    to_release   INTEGER,
     meta         BLOB,
-	UNIQUE(package,release,from_release)
+    UNIQUE(package,release,to_release)
+)
+`
+
+// SkipSchema is the SQLite3 schema for the Delta Skips table
+const SkipSchema = `
+CREATE TABLE IF NOT EXISTS delta_skips (
+    package      STRING,
+    release      INTEGER,
+    to_release   INTEGER,
+    reason       TEXT,
+    UNIQUE(package,release,to_release)
 )
 `
 
 // Queries for retrieving Archives
 const packageArchives = "SELECT * FROM archives WHERE name=:name"
 
+// GetByURI retrieves a single Archive by its location in the repos
+const GetByURI = "SELECT * FROM archives WHERE uri=?"
+
+// FindByName retrieves any Archives whose URI ends with the provided pattern
+const FindByName = "SELECT * FROM archives WHERE uri LIKE ?"
+
+// GetDelta retrieves a single Delta Archive by package and releases
+const GetDelta = "SELECT * FROM archives WHERE package=? AND release=? AND to_release=?"
+
+// RepoArchives retrieves all of the Archives linked to a Repo
+const RepoArchives = `
+WITH ids AS (
+    SELECT archive_id FROM packages
+    WHERE repo_id=?
+)
+SELECT archives.* FROM archives
+INNER JOIN ids ON ids.archive_id = archives.id
+`
+
 // Insert Query for creating a new Archive
 const Insert = `
 INSERT INTO archives (
-    id, package, uri, size, hash, release, from_release, meta
+    id, package, uri, size, hash, release, to_release, meta
 ) VALUES (
-    NULL, :package, :uri, :size, :hash, :release, :from_release, :meta
+    NULL, :package, :uri, :size, :hash, :release, :to_release, :meta
 )
 `
 
 // Update Query for updating an Archive record
 const Update = "UPDATE archives SET size=:size, hash=:hash, meta=:meta WHERE id=:id"
 
-// Queries for removing Archives
+// Remove Query for deleting an Archive record
+const Remove = "DELETE FROM archives WHERE id=:id"
+
+// Queries for Deltas which should not be generated
 const (
-	trimPackages  = "DELETE FROM archives WHERE name=:name AND release < :release"
-	trimObsoletes = "DELETE FROM archives WHERE name=:name"
-	removeArchive = "DELETE FROM archives WHERE id=:id"
+	InsertSkip = "INSERT OR REPLACE INTO delta_skips (package, release, to_release, reason) VALUES (?, ?, ?, ?)"
+	GetSkip    = "SELECT reason FROM delta_skips WHERE package=? AND release=? AND to_release=?"
 )
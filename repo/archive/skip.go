@@ -0,0 +1,40 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package archive
+
+import (
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+)
+
+// MarkSkipped records that a Delta should never be generated, along with the reason why
+func MarkSkipped(tx *sqlx.Tx, pkg string, from, to int, reason string) error {
+	_, err := tx.Exec(InsertSkip, pkg, from, to, reason)
+	return err
+}
+
+// IsSkipped checks if a Delta has previously been marked as not worth generating
+func IsSkipped(tx *sqlx.Tx, pkg string, from, to int) (skipped bool, err error) {
+	var reason string
+	switch err = tx.Get(&reason, GetSkip, pkg, from, to); err {
+	case nil:
+		skipped = true
+	case sql.ErrNoRows:
+		err = nil
+	}
+	return
+}
@@ -45,6 +45,7 @@ func OpenDB() *sqlx.DB {
 	db.MustExec(Schema)
 	db.MustExec(pkgs.Schema)
 	db.MustExec(archive.Schema)
+	db.MustExec(archive.SkipSchema)
 	// Check that the repos directory exists
 	if err = util.CreateDir(config.Current.RepoPath()); err != nil {
 		panic(err.Error())
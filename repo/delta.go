@@ -0,0 +1,215 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/getsolus/ferryd/config"
+	"github.com/getsolus/ferryd/core"
+	"github.com/getsolus/ferryd/jobs"
+	"github.com/getsolus/ferryd/repo/archive"
+	eopkg "github.com/getsolus/libeopkg/archive"
+	"github.com/jmoiron/sqlx"
+	"os"
+	"path/filepath"
+)
+
+// MaxDeltaRatio is the largest a Delta may be, as a fraction of the package it upgrades to, before it is not worth keeping
+const MaxDeltaRatio = 0.75
+
+// ErrPoolDelta is returned when trying to generate Deltas for the pool itself
+var ErrPoolDelta = errors.New("deltas cannot be generated for the pool")
+
+// Reasons for skipping a Delta
+const (
+	skipPointless = "pointless"
+	skipMismatch  = "mismatched"
+	skipTooLarge  = "not worth it"
+)
+
+// deltaBuilder generates the Deltas for a single Repo
+type deltaBuilder struct {
+	tx   *sqlx.Tx
+	job  *jobs.Job
+	repo *Repo
+	pool *Repo
+	diff *Diff
+}
+
+// newDeltaBuilder sets up a deltaBuilder for a Repo
+func newDeltaBuilder(r *Repo, j *jobs.Job, tx *sqlx.Tx) (b *deltaBuilder, err error) {
+	pool, err := Pool(tx)
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(config.Current.DeltaPath(), 0755); err != nil {
+		return
+	}
+	b = &deltaBuilder{
+		tx:   tx,
+		job:  j,
+		repo: r,
+		pool: pool,
+		diff: &Diff{},
+	}
+	return
+}
+
+// deltaURI determines the location of a Delta, next to the package it upgrades to
+func deltaURI(from, to *archive.Archive) (uri string, err error) {
+	meta, err := to.Metadata()
+	if err != nil {
+		return
+	}
+	name := fmt.Sprintf("%s-%d-%d-%d-%s%s", to.Package, from.Release, to.Release,
+		meta.Package.DistributionRelease, meta.Package.Architecture, archive.DeltaSuffix)
+	uri = filepath.Join(filepath.Dir(to.URI), name)
+	return
+}
+
+// build generates any missing Deltas for a single package and drops the ones which are out of date
+func (b *deltaBuilder) build(as archive.Archives) error {
+	// Find the latest release
+	var latest *archive.Archive
+	for i := range as {
+		if as[i].IsPackage() && (latest == nil || latest.Release < as[i].Release) {
+			latest = &as[i]
+		}
+	}
+	if latest == nil {
+		return nil
+	}
+	// Find the deltas which are still needed
+	have := make(map[int]bool)
+	for i := range as {
+		a := &as[i]
+		if !a.IsDelta() {
+			continue
+		}
+		if a.To == latest.Release {
+			have[a.Release] = true
+			continue
+		}
+		// Out of date
+		b.job.Log().Infof("Removing out of date delta '%s'\n", a.URI)
+		if err := b.repo.removeArchive(b.tx, b.pool, a); err != nil {
+			return err
+		}
+		b.diff.add(*a, archive.StatusRemoved)
+	}
+	// Produce a delta from each of the older releases
+	for i := range as {
+		a := &as[i]
+		if !a.IsPackage() || a.Release >= latest.Release || have[a.Release] {
+			continue
+		}
+		if err := b.produce(a, latest); err != nil {
+			return fmt.Errorf("failed to produce delta for '%s' from %d to %d, reason: '%s'",
+				a.Package, a.Release, latest.Release, err.Error())
+		}
+	}
+	return nil
+}
+
+// produce generates a single Delta, reusing an existing one from the pool if possible
+func (b *deltaBuilder) produce(from, to *archive.Archive) error {
+	// Check for an existing Delta
+	var delta archive.Archive
+	err := b.tx.Get(&delta, archive.GetDelta, to.Package, from.Release, to.Release)
+	switch err {
+	case nil:
+		return b.link(&delta)
+	case sql.ErrNoRows:
+	default:
+		return err
+	}
+	// Don't bother with deltas that failed before
+	skipped, err := archive.IsSkipped(b.tx, to.Package, from.Release, to.Release)
+	if err != nil || skipped {
+		return err
+	}
+	uri, err := deltaURI(from, to)
+	if err != nil {
+		return err
+	}
+	// Build the Delta
+	tmp := filepath.Join(config.Current.DeltaPath(), filepath.Base(uri))
+	defer os.Remove(tmp)
+	switch err = core.ProduceDelta(config.Current.DeltaPath(), b.repo.ArchivePath(from), b.repo.ArchivePath(to), tmp); err {
+	case nil:
+	case eopkg.ErrDeltaPointless:
+		return b.skip(from, to, skipPointless)
+	case eopkg.ErrMismatchedDelta:
+		return b.skip(from, to, skipMismatch)
+	default:
+		return err
+	}
+	// Make sure it is worth keeping
+	st, err := os.Stat(tmp)
+	if err != nil {
+		return err
+	}
+	if float64(st.Size()) > MaxDeltaRatio*float64(to.Size) {
+		return b.skip(from, to, skipTooLarge)
+	}
+	// Move it into the pool
+	pp := filepath.Join(b.pool.Path(), uri)
+	if err = b.repo.stageFile(tmp, pp); err != nil {
+		return err
+	}
+	a, err := archive.Open(pp, uri)
+	if err != nil {
+		return err
+	}
+	if err = a.Save(b.tx); err != nil {
+		return err
+	}
+	if err = b.pool.addLink(b.tx, a); err != nil {
+		return err
+	}
+	b.job.Log().Infof("Produced delta '%s'\n", uri)
+	return b.link(a)
+}
+
+// skip records that a Delta should not be attempted again
+func (b *deltaBuilder) skip(from, to *archive.Archive, reason string) error {
+	b.job.Log().Infof("Skipping delta for '%s' from %d to %d, reason: '%s'\n", to.Package, from.Release, to.Release, reason)
+	return archive.MarkSkipped(b.tx, to.Package, from.Release, to.Release, reason)
+}
+
+// link adds an existing Delta from the pool to the Repo
+func (b *deltaBuilder) link(a *archive.Archive) error {
+	if err := b.repo.stageLink(b.pool, a); err != nil {
+		return err
+	}
+	if err := b.repo.addLink(b.tx, a); err != nil {
+		return err
+	}
+	b.diff.add(*a, archive.StatusAdded)
+	return nil
+}
+
+// publish generates a new index including the new Deltas
+func (b *deltaBuilder) publish() (*Diff, error) {
+	if err := b.repo.stageIndex(b.tx); err != nil {
+		return nil, err
+	}
+	b.diff.sort()
+	return b.diff, nil
+}
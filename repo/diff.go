@@ -20,17 +20,71 @@ import (
 	"bytes"
 	"encoding/gob"
 	"github.com/getsolus/ferryd/repo/archive"
+	"github.com/getsolus/ferryd/repo/release"
 	"io"
+	"sort"
 )
 
 // Diff is a list of changes made to a repo
 type Diff archive.Archives
 
+// add appends a copy of an Archive to the Diff with the specified Status
+func (d *Diff) add(a archive.Archive, status archive.Status) {
+	entry := a.Copy()
+	// The metadata is not needed to describe a change
+	entry.Meta = nil
+	entry.Status = status
+	*d = append(*d, entry)
+}
+
+// sort orders the Diff by package, release and delta
+func (d Diff) sort() {
+	sort.Sort(archive.Archives(d))
+}
+
+// compare adds the differences between the Releases of the same package in two repos, from the point of view of "right"
+func (d *Diff) compare(left, right release.Releases) {
+	type key struct {
+		release, to int
+	}
+	theirs := make(map[key]archive.Archive)
+	for _, a := range right.Archives() {
+		theirs[key{a.Release, a.To}] = a
+	}
+	for _, a := range left.Archives() {
+		k := key{a.Release, a.To}
+		_, ok := theirs[k]
+		// Both sides share a single Archive record for the same release, so it can't differ
+		if ok {
+			d.add(a, archive.StatusUnchanged)
+		} else {
+			d.add(a, archive.StatusAdded)
+		}
+		delete(theirs, k)
+	}
+	// Anything left over only exists on the right
+	for _, a := range theirs {
+		d.add(a, archive.StatusRemoved)
+	}
+}
+
+// changes filters out any unchanged Archives
+func (d Diff) changes() *Diff {
+	changes := make(Diff, 0)
+	for _, a := range d {
+		if a.Status != archive.StatusUnchanged {
+			changes = append(changes, a)
+		}
+	}
+	return &changes
+}
+
 // MarshalBinary converts a Diff to its Gob encoded form
 func (d *Diff) MarshalBinary() (data []byte, err error) {
 	buff := bytes.NewBuffer([]byte{})
 	enc := gob.NewEncoder(buff)
-	if err = enc.Encode(d); err == nil {
+	// Encode as plain Archives, otherwise gob calls MarshalBinary again
+	if err = enc.Encode(archive.Archives(*d)); err == nil {
 		data = buff.Bytes()
 	}
 	return
@@ -40,10 +94,10 @@ func (d *Diff) MarshalBinary() (data []byte, err error) {
 func (d *Diff) UnmarshalBinary(data []byte) error {
 	buff := bytes.NewBuffer(data)
 	dec := gob.NewDecoder(buff)
-	return dec.Decode(d)
+	return dec.Decode((*archive.Archives)(d))
 }
 
-// Print writes out a Diff in a human-readable format
+// Print writes out a Diff in a human-readable format, only including unchanged Archives when "full" is set
 func (d Diff) Print(out io.Writer, full, color bool) {
 	plus := "+%s\n"
 	minus := "-%s\n"
@@ -58,6 +112,9 @@ func (d Diff) Print(out io.Writer, full, color bool) {
 	}
 	// Print each line
 	for _, a := range d {
+		if !full && a.Status == archive.StatusUnchanged {
+			continue
+		}
 		a.PrintDiff(out, plus, minus, mod, same)
 	}
 }
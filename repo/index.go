@@ -0,0 +1,197 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package repo
+
+import (
+	"fmt"
+	"github.com/getsolus/ferryd/repo/archive"
+	"github.com/getsolus/libeopkg/index"
+	"github.com/jmoiron/sqlx"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	// IndexFile is the name of the uncompressed index for a repo
+	IndexFile = "eopkg-index.xml"
+	// DistributionFile is the name of the distribution asset for a repo
+	DistributionFile = "distribution.xml"
+	// ComponentsFile is the name of the components asset for a repo
+	ComponentsFile = "components.xml"
+	// GroupsFile is the name of the groups asset for a repo
+	GroupsFile = "groups.xml"
+	// MaxHistory is the maximum number of History entries kept for a Package in the index
+	MaxHistory = 10
+)
+
+// indexFiles lists the files produced by an Index, in the order they are published. Clients
+// read a sha1sum to decide whether to fetch its index, so the sums go last, and an index is
+// never published alongside the sum of an older one.
+var indexFiles = []string{
+	IndexFile,
+	IndexFile + ".xz",
+	IndexFile + ".sha1sum",
+	IndexFile + ".xz.sha1sum",
+}
+
+// Archives retrieves all of the Archives linked to this Repo
+func (r *Repo) Archives(tx *sqlx.Tx) (as archive.Archives, err error) {
+	as = make(archive.Archives, 0)
+	if err = tx.Select(&as, archive.RepoArchives, r.ID); err != nil {
+		return
+	}
+	sort.Sort(as)
+	return
+}
+
+// buildIndex generates a full eopkg index from the DB records and assets of a repo
+func (r *Repo) buildIndex(tx *sqlx.Tx) (idx *index.Index, err error) {
+	idx = &index.Index{}
+	// Read in the assets
+	ap := r.AssetPath()
+	dist, err := index.NewDistribution(filepath.Join(ap, DistributionFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read distribution for '%s', reason: '%s'", r.Name, err.Error())
+	}
+	idx.Distribution = *dist
+	comps, err := index.NewComponents(filepath.Join(ap, ComponentsFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read components for '%s', reason: '%s'", r.Name, err.Error())
+	}
+	idx.Components = comps.Components
+	groups, err := index.NewGroups(filepath.Join(ap, GroupsFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read groups for '%s', reason: '%s'", r.Name, err.Error())
+	}
+	idx.Groups = groups.Groups
+	// Find the latest release of every package
+	as, err := r.Archives(tx)
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]archive.Archive)
+	for _, a := range as {
+		if !a.IsPackage() || dist.IsObsolete(a.Package) {
+			continue
+		}
+		if prev, ok := latest[a.Package]; !ok || prev.Release < a.Release {
+			latest[a.Package] = a
+		}
+	}
+	// Collect the deltas which lead to the latest releases
+	deltas := make(map[string][]index.Delta)
+	for _, a := range as {
+		if !a.IsDelta() {
+			continue
+		}
+		if l, ok := latest[a.Package]; !ok || l.Release != a.To {
+			continue
+		}
+		deltas[a.Package] = append(deltas[a.Package], index.Delta{
+			ReleaseFrom: a.Release,
+			PackageURI:  a.URI,
+			PackageSize: int64(a.Size),
+			PackageHash: a.Hash,
+		})
+	}
+	// Convert each package into an index entry
+	names := make([]string, 0, len(latest))
+	for name := range latest {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		a := latest[name]
+		var p *index.Package
+		if p, err = indexPackage(&a); err != nil {
+			return nil, fmt.Errorf("failed to index '%s', reason: '%s'", a.URI, err.Error())
+		}
+		if ds := deltas[name]; len(ds) > 0 {
+			p.DeltaPackages = &ds
+		}
+		idx.Packages = append(idx.Packages, *p)
+	}
+	return
+}
+
+// indexPackage converts the metadata of a package Archive into an index entry
+func indexPackage(a *archive.Archive) (p *index.Package, err error) {
+	meta, err := a.Metadata()
+	if err != nil {
+		return
+	}
+	pkg := meta.Package
+	p = &index.Package{
+		Name:                pkg.Name,
+		IsA:                 pkg.IsA,
+		PartOf:              pkg.PartOf,
+		Licenses:            pkg.License,
+		Replaces:            pkg.Replaces,
+		Conflicts:           pkg.Conflicts,
+		History:             pkg.History,
+		BuildHost:           pkg.BuildHost,
+		Distribution:        pkg.Distribution,
+		DistributionRelease: pkg.DistributionRelease,
+		Architecture:        pkg.Architecture,
+		InstalledSize:       int(pkg.InstalledSize),
+		PackageSize:         a.Size,
+		PackageHash:         a.Hash,
+		PackageURI:          a.URI,
+		PackageFormat:       pkg.PackageFormat,
+		Source:              meta.Source,
+	}
+	if len(pkg.Summary) > 0 {
+		p.Summary = pkg.Summary[0]
+	}
+	if len(pkg.Description) > 0 {
+		p.Description = pkg.Description[0]
+	}
+	if pkg.RuntimeDependencies != nil {
+		p.RuntimeDependencies = *pkg.RuntimeDependencies
+	}
+	if len(pkg.Provides.COMAR) > 0 || len(pkg.Provides.PkgConfig) > 0 || len(pkg.Provides.PkgConfig32) > 0 {
+		provides := pkg.Provides
+		p.Provides = &provides
+	}
+	if len(p.History) > MaxHistory {
+		p.History = p.History[:MaxHistory]
+	}
+	return
+}
+
+// writeIndex saves an index to a temporary location and then moves it into place
+func (r *Repo) writeIndex(idx *index.Index) error {
+	rp := r.Path()
+	// Write the index next to the repo so that the renames are atomic
+	tmp, err := ioutil.TempDir(rp, ".index-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary index dir, reason: '%s'", err.Error())
+	}
+	defer os.RemoveAll(tmp)
+	if err = idx.Save(tmp); err != nil {
+		return fmt.Errorf("failed to write index, reason: '%s'", err.Error())
+	}
+	// Move each of the files into place
+	for _, name := range indexFiles {
+		if err = os.Rename(filepath.Join(tmp, name), filepath.Join(rp, name)); err != nil {
+			return fmt.Errorf("failed to publish '%s', reason: '%s'", name, err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package repo
+
+import (
+	"fmt"
+	"github.com/getsolus/ferryd/core"
+	"github.com/getsolus/ferryd/repo/archive"
+	"github.com/jmoiron/sqlx"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// move is a file which has been set aside, so that it can be put back
+type move struct {
+	orig   string
+	staged string
+}
+
+// journal records the changes made to a Repo on disk, so that they can be undone along with the DB
+type journal struct {
+	staging string
+	created []string
+	linked  []string
+	moved   []move
+	removed []string
+}
+
+// begin starts recording changes to this Repo, if not already doing so
+func (r *Repo) begin() (jr *journal, err error) {
+	if r.journal != nil {
+		return r.journal, nil
+	}
+	// Keep the staging area on the same filesystem as the repo, so that moves are cheap
+	staging, err := ioutil.TempDir(r.Path(), ".journal-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create journal for '%s', reason: '%s'", r.Name, err.Error())
+	}
+	r.journal = &journal{
+		staging: staging,
+	}
+	return r.journal, nil
+}
+
+// stage sets a file aside in the staging area
+func (jr *journal) stage(path string) error {
+	staged := filepath.Join(jr.staging, strconv.Itoa(len(jr.moved)))
+	if err := os.Rename(path, staged); err != nil {
+		return err
+	}
+	jr.moved = append(jr.moved, move{path, staged})
+	return nil
+}
+
+// backup keeps a copy of a file in the staging area, so that it can be restored after being replaced
+func (jr *journal) backup(path string) error {
+	staged := filepath.Join(jr.staging, strconv.Itoa(len(jr.moved)))
+	if err := os.Link(path, staged); err != nil {
+		return err
+	}
+	jr.moved = append(jr.moved, move{path, staged})
+	return nil
+}
+
+// stageLink links an Archive from the pool into this Repo, recording any new file
+func (r *Repo) stageLink(pool *Repo, a *archive.Archive) error {
+	jr, err := r.begin()
+	if err != nil {
+		return err
+	}
+	created, err := r.linkArchive(pool, a)
+	if err != nil {
+		return err
+	}
+	if created {
+		jr.created = append(jr.created, r.ArchivePath(a))
+		jr.linked = append(jr.linked, r.ArchivePath(a))
+	}
+	return nil
+}
+
+// stageFile links a new file into place, removing it again if the changes are reverted
+func (r *Repo) stageFile(src, dst string) error {
+	jr, err := r.begin()
+	if err != nil {
+		return err
+	}
+	if err = linkFile(src, dst); err != nil {
+		return err
+	}
+	jr.created = append(jr.created, dst)
+	jr.linked = append(jr.linked, dst)
+	return nil
+}
+
+// stageRemove sets aside the file for an Archive in this Repo, until the changes are kept
+func (r *Repo) stageRemove(a *archive.Archive) error {
+	return r.stagePath(r.ArchivePath(a))
+}
+
+// stagePath sets aside an Archive file, until the changes to this Repo are kept
+func (r *Repo) stagePath(path string) error {
+	jr, err := r.begin()
+	if err != nil {
+		return err
+	}
+	if err = jr.stage(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	jr.removed = append(jr.removed, path)
+	return nil
+}
+
+// stageIndex regenerates the index for this Repo, keeping the previous one until the changes are kept
+func (r *Repo) stageIndex(tx *sqlx.Tx) error {
+	jr, err := r.begin()
+	if err != nil {
+		return err
+	}
+	idx, err := r.buildIndex(tx)
+	if err != nil {
+		return err
+	}
+	for _, name := range indexFiles {
+		path := filepath.Join(r.Path(), name)
+		if err = jr.backup(path); err != nil {
+			if !os.IsNotExist(err) {
+				return err
+			}
+			jr.created = append(jr.created, path)
+		}
+	}
+	return r.writeIndex(idx)
+}
+
+// CommitDisk keeps any changes made to this Repo on disk
+func (r *Repo) CommitDisk() error {
+	jr := r.journal
+	if jr == nil {
+		return nil
+	}
+	r.journal = nil
+	// Clean up directories left empty by removals
+	for _, path := range jr.removed {
+		core.RemovePackageParents(path)
+	}
+	return os.RemoveAll(jr.staging)
+}
+
+// RevertDisk undoes any changes made to this Repo on disk
+func (r *Repo) RevertDisk() error {
+	jr := r.journal
+	if jr == nil {
+		return nil
+	}
+	r.journal = nil
+	var err error
+	for _, path := range jr.created {
+		if rerr := os.Remove(path); rerr != nil && !os.IsNotExist(rerr) {
+			err = rerr
+		}
+	}
+	// Put things back in the reverse order they were moved
+	for i := len(jr.moved) - 1; i >= 0; i-- {
+		m := jr.moved[i]
+		if rerr := os.Rename(m.staged, m.orig); rerr != nil {
+			err = rerr
+		}
+	}
+	// Clean up directories left empty by new links
+	for _, path := range jr.linked {
+		core.RemovePackageParents(path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to revert changes to '%s', reason: '%s'", r.Name, err.Error())
+	}
+	return os.RemoveAll(jr.staging)
+}
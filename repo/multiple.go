@@ -17,28 +17,165 @@
 package repo
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/getsolus/ferryd/jobs"
+	"github.com/getsolus/ferryd/repo/archive"
+	"github.com/getsolus/ferryd/repo/pkgs"
+	"github.com/getsolus/ferryd/repo/release"
+	"github.com/getsolus/ferryd/util"
 	"github.com/jmoiron/sqlx"
+	"path/filepath"
 )
 
+// ErrPoolDest is returned when trying to modify the pool as if it were a normal Repo
+var ErrPoolDest = errors.New("the pool cannot be modified directly")
+
+// applyChange makes a single change from a Diff to this Repo, on disk and in the DB
+func (r *Repo) applyChange(tx *sqlx.Tx, pool *Repo, a archive.Archive) (err error) {
+	switch a.Status {
+	case archive.StatusAdded:
+		if err = r.stageLink(pool, &a); err != nil {
+			return
+		}
+		err = r.addLink(tx, &a)
+	case archive.StatusRemoved:
+		err = r.removeArchive(tx, pool, &a)
+	}
+	return
+}
+
 // CherryPick syncs a single package from this repo to another
-func CherryPick(left, right *Repo, j *jobs.Job, tx *sqlx.Tx) (d *Diff, err error) {
-	if d, err = Compare(left, right, j, tx); err != nil {
+func CherryPick(ctx context.Context, left, right *Repo, j *jobs.Job, tx *sqlx.Tx) (d *Diff, err error) {
+	if len(j.Pkg) == 0 {
+		return nil, errors.New("job is missing a package name")
+	}
+	// Refuse to remove a package which the source doesn't have
+	rs, err := release.GetReleases(tx, left.Name, j.Pkg)
+	if err != nil {
 		return
 	}
-	// TODO: Implement
-	return nil, errors.New("Function not implemented")
+	if len(rs) == 0 {
+		return nil, fmt.Errorf("package '%s' not found in '%s'", j.Pkg, left.Name)
+	}
+	return apply(ctx, left, right, j, tx)
+}
+
+// Clone fills a newly created repo with the contents of this one
+func Clone(ctx context.Context, left, right *Repo, j *jobs.Job, tx *sqlx.Tx) (s Summary, err error) {
+	if right.IsPool() {
+		return s, ErrPoolDest
+	}
+	// Duplicate the links in one go
+	if _, err = tx.Exec(pkgs.CopyRepo, right.ID, left.ID); err != nil {
+		return
+	}
+	pool, err := Pool(tx)
+	if err != nil {
+		return
+	}
+	as, err := left.Archives(tx)
+	if err != nil {
+		return
+	}
+	// Hardlink the tree from the pool
+	p := j.Progress()
+	p.Phase("archives", len(as))
+	for i := range as {
+		// Stop between archives if cancelled
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if err = right.stageLink(pool, &as[i]); err != nil {
+			return s, fmt.Errorf("failed to link '%s', reason: '%s'", filepath.Base(as[i].URI), err.Error())
+		}
+		p.Step(int64(as[i].Size))
+	}
+	// Use the same assets
+	if err = util.CopyDir(left.AssetPath(), right.AssetPath(), false); err != nil {
+		return s, fmt.Errorf("failed to copy assets, reason: '%s'", err.Error())
+	}
+	p.Phase("indexing", 0)
+	if err = right.stageIndex(tx); err != nil {
+		return
+	}
+	return right.Summarize(tx)
 }
 
 // Compare the contents of this repo to another
-func Compare(left, right *Repo, j *jobs.Job, tx *sqlx.Tx) (d *Diff, err error) {
-	// TODO: Implement, checking for j.Pkg to be set to limit the size of the comparison
-	return nil, errors.New("Function not implemented")
+func Compare(ctx context.Context, left, right *Repo, j *jobs.Job, tx *sqlx.Tx) (d *Diff, err error) {
+	d = &Diff{}
+	// Limit the comparison to a single package when requested
+	if len(j.Pkg) > 0 {
+		var lrs, rrs release.Releases
+		if lrs, err = release.GetReleases(tx, left.Name, j.Pkg); err != nil {
+			return nil, err
+		}
+		if rrs, err = release.GetReleases(tx, right.Name, j.Pkg); err != nil {
+			return nil, err
+		}
+		d.compare(lrs, rrs)
+		d.sort()
+		return
+	}
+	// Compare every package in either repo
+	lm, err := release.GetAllReleases(tx, left.Name)
+	if err != nil {
+		return nil, err
+	}
+	rm, err := release.GetAllReleases(tx, right.Name)
+	if err != nil {
+		return nil, err
+	}
+	for name, lrs := range lm {
+		d.compare(lrs, rm[name])
+		delete(rm, name)
+	}
+	for _, rrs := range rm {
+		d.compare(nil, rrs)
+	}
+	d.sort()
+	return
 }
 
 // Sync all packages from this repo to another
-func Sync(left, right *Repo, j *jobs.Job, tx *sqlx.Tx) (d *Diff, err error) {
-	// TODO: Implement
-	return nil, errors.New("Function not implemented")
+func Sync(ctx context.Context, left, right *Repo, j *jobs.Job, tx *sqlx.Tx) (d *Diff, err error) {
+	return apply(ctx, left, right, j, tx)
+}
+
+// apply makes the contents of "right" match "left", limited to a single package if j.Pkg is set
+func apply(ctx context.Context, left, right *Repo, j *jobs.Job, tx *sqlx.Tx) (d *Diff, err error) {
+	if right.IsPool() {
+		return nil, ErrPoolDest
+	}
+	// Work out what needs to change
+	full, err := Compare(ctx, left, right, j, tx)
+	if err != nil {
+		return
+	}
+	d = full.changes()
+	pool, err := Pool(tx)
+	if err != nil {
+		return nil, err
+	}
+	// Apply each change to the destination
+	p := j.Progress()
+	p.Phase("changes", len(*d))
+	for _, a := range *d {
+		// Stop between changes if cancelled
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err = right.applyChange(tx, pool, a); err != nil {
+			return nil, fmt.Errorf("failed to sync '%s', reason: '%s'", filepath.Base(a.URI), err.Error())
+		}
+		p.Step(int64(a.Size))
+	}
+	// Publish the changes
+	p.Phase("indexing", 0)
+	if err = right.stageIndex(tx); err != nil {
+		return nil, err
+	}
+	return
 }
@@ -22,8 +22,8 @@ import (
 
 // Package is an entry in the Package Table
 type Package struct {
-	RepoID    int
-	ReleaseID int
+	RepoID    int `db:"repo_id"`
+	ArchiveID int `db:"archive_id"`
 }
 
 // Save adds a new entry to the package table
@@ -20,46 +20,56 @@ package pkgs
 const Schema = `
 CREATE TABLE IF NOT EXISTS packages (
     repo_id    INTEGER,
-    release_id INTEGER,
-    UNIQUE(repo_id,release_id)
+    archive_id INTEGER,
+    UNIQUE(repo_id,archive_id)
 )
 `
 
 // RepoReleases gets all the releases for a repo
 const RepoReleases = `
 WITH ids AS (
-    SELECT release_id FROM packages
-    WHERE repo_id=(SELECT repo_id FROM repos WHERE name=?)
+    SELECT archive_id FROM packages
+    WHERE repo_id=(SELECT id FROM repos WHERE name=?)
 )
-SELECT id, package, uri, size, hash, release, from_release, meta FROM releases
-INNER JOIN ids ON ids.release_id = releases.id
+SELECT id, package, uri, size, hash, release, to_release, meta FROM archives
+INNER JOIN ids ON ids.archive_id = archives.id
 `
 
 // PackageReleases gets all the releases for Package in a Repo
 const PackageReleases = `
 WITH ids AS (
-    SELECT release_id FROM packages
-    WHERE repo_id IN (SELECT repo_id FROM repos WHERE name=?)
+    SELECT archive_id FROM packages
+    WHERE repo_id IN (SELECT id FROM repos WHERE name=?)
 )
-SELECT * FROM releases
-INNER JOIN ids ON ids.release_id = releases.id
-WHERE releases.package=?
+SELECT archives.* FROM archives
+INNER JOIN ids ON ids.archive_id = archives.id
+WHERE archives.package=?
 `
 
-// Insert Query for creating a new Package entry
+// Insert Query for creating a new Package entry, ignoring existing entries
 const Insert = `
-INSERT INTO packages (
-    repo_id, release_id
+INSERT OR IGNORE INTO packages (
+    repo_id, archive_id
 ) VALUES (
-    :repo_id, :release_id
+    :repo_id, :archive_id
 )
 `
 
+// CopyRepo duplicates every Package entry for one repo into another
+const CopyRepo = `
+INSERT OR IGNORE INTO packages (
+    repo_id, archive_id
+) SELECT ?, archive_id FROM packages WHERE repo_id=?
+`
+
+// CountLinks counts the Repos, other than the one specified, which link to an Archive
+const CountLinks = "SELECT COUNT(*) FROM packages WHERE archive_id=? AND repo_id!=?"
+
 const (
-	// Remove deletes a specific package entry with a repo_id and a release_id
-	Remove = "DELETE FROM packages WHERE repo_id=:repo_id AND release_id=:release_id"
+	// Remove deletes a specific package entry with a repo_id and an archive_id
+	Remove = "DELETE FROM packages WHERE repo_id=:repo_id AND archive_id=:archive_id"
 	// RemoveByRepo all package entries for a given repo
 	RemoveByRepo = "DELETE FROM packages WHERE repo_id=:repo_id"
-	// RemoveByRelease all package entries for a fiven release
-	RemoveByRelease = "DELETE FROM packages WHERE release_id=:release_id"
+	// RemoveByArchive all package entries for a given archive
+	RemoveByArchive = "DELETE FROM packages WHERE archive_id=:archive_id"
 )
@@ -0,0 +1,135 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package repo
+
+import (
+	"fmt"
+	"github.com/getsolus/ferryd/core"
+	"github.com/getsolus/ferryd/repo/archive"
+	"github.com/getsolus/ferryd/repo/pkgs"
+	"github.com/jmoiron/sqlx"
+	"os"
+	"path/filepath"
+)
+
+// PoolName is the reserved name of the pseudo-repo containing every Archive
+const PoolName = "pool"
+
+// Pool retrieves the pool Repo
+func Pool(tx *sqlx.Tx) (*Repo, error) {
+	return Get(tx, PoolName)
+}
+
+// IsPool checks if this Repo is the pool
+func (r *Repo) IsPool() bool {
+	return r.Name == PoolName
+}
+
+// ArchivePath returns the location of an Archive within this Repo
+func (r *Repo) ArchivePath(a *archive.Archive) string {
+	return filepath.Join(r.Path(), a.URI)
+}
+
+// linkFile hardlinks or copies a file, creating any missing parent directories
+func linkFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return core.LinkOrCopyFile(src, dst, false)
+}
+
+// linkArchive hardlinks an Archive from the pool into this Repo, reporting if a new file was created
+func (r *Repo) linkArchive(pool *Repo, a *archive.Archive) (created bool, err error) {
+	src := pool.ArchivePath(a)
+	dst := r.ArchivePath(a)
+	dstInfo, err := os.Stat(dst)
+	if os.IsNotExist(err) {
+		if err = linkFile(src, dst); err != nil {
+			return
+		}
+		created = true
+		return
+	}
+	if err != nil {
+		return
+	}
+	// Already linked, nothing to do
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return
+	}
+	if os.SameFile(srcInfo, dstInfo) {
+		return
+	}
+	// Fall back to checking the contents, in case the file had to be copied
+	hash, err := core.FileSHA1Sum(dst)
+	if err != nil {
+		return
+	}
+	if hash != a.Hash {
+		err = fmt.Errorf("archive '%s' already exists in '%s' with a different hash", filepath.Base(a.URI), r.Name)
+	}
+	return
+}
+
+// addLink inserts a record linking an Archive to this Repo
+func (r *Repo) addLink(tx *sqlx.Tx, a *archive.Archive) error {
+	p := &pkgs.Package{
+		RepoID:    r.ID,
+		ArchiveID: a.ID,
+	}
+	return p.Save(tx)
+}
+
+// removeArchive unlinks an Archive from this Repo, dropping it from the pool if nothing else needs it
+func (r *Repo) removeArchive(tx *sqlx.Tx, pool *Repo, a *archive.Archive) error {
+	if err := r.removeLink(tx, a); err != nil {
+		return err
+	}
+	if err := r.stageRemove(a); err != nil {
+		return err
+	}
+	return r.dropOrphan(tx, pool, a)
+}
+
+// dropOrphan deletes an Archive from the pool, once no other Repo links to it
+func (r *Repo) dropOrphan(tx *sqlx.Tx, pool *Repo, a *archive.Archive) error {
+	var links int
+	if err := tx.Get(&links, pkgs.CountLinks, a.ID, pool.ID); err != nil {
+		return err
+	}
+	if links > 0 {
+		return nil
+	}
+	if err := pool.removeLink(tx, a); err != nil {
+		return err
+	}
+	if err := a.Remove(tx); err != nil {
+		return err
+	}
+	// The pool copy is only deleted once the changes to this Repo are kept
+	return r.stagePath(pool.ArchivePath(a))
+}
+
+// removeLink deletes the record linking an Archive to this Repo
+func (r *Repo) removeLink(tx *sqlx.Tx, a *archive.Archive) error {
+	p := &pkgs.Package{
+		RepoID:    r.ID,
+		ArchiveID: a.ID,
+	}
+	return p.Remove(tx)
+}
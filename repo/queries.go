@@ -51,7 +51,7 @@ WITH ids AS (
 )
 SELECT count(*) FROM archives
 INNER JOIN ids ON ids.archive_id = archives.id
-WHERE to_release IS NULL
+WHERE to_release=0
 `
 
 // DeltaCount gets the number of deltas in a repo
@@ -62,7 +62,7 @@ WITH ids AS (
 )
 SELECT count(*) FROM archives
 INNER JOIN ids ON ids.archive_id = archives.id
-WHERE to_release IS NOT NULL
+WHERE to_release>0
 `
 
 // Insert is a Query for creating a new Repo
@@ -27,31 +27,20 @@ type Map map[string]Releases
 
 // GetAllReleases retrieves all of the Releases for all packages in a repo
 func GetAllReleases(tx *sqlx.Tx, repo string) (m Map, err error) {
-	var as archive.Archives
-	if err = tx.Get(&as, GetRepoArchives, repo); err != nil {
+	as := make(archive.Archives, 0)
+	if err = tx.Select(&as, GetRepoArchives, repo); err != nil {
 		return
 	}
 	sort.Sort(as)
-	// Sort Archives into Releases
-	var r *Release
-	var rs Releases
-	for _, a := range as {
-		if r == nil {
-			r = &Release{}
-		} else if r.Package() != a.Package {
-			m[r.Package()] = rs
-			rs = make(Releases, 0)
-			r = &Release{}
-		} else if r.Number() != a.Release {
-			rs = append(rs, *r)
-			r = &Release{}
-		}
-		if a.IsPackage() {
-			d := a.Copy()
-			r.Pkg = &d
-		} else if a.IsDelta() {
-			r.Deltas = append(r.Deltas, a.Copy())
+	// Sort Archives into Releases, one package at a time
+	m = make(Map)
+	for start := 0; start < len(as); {
+		end := start
+		for end < len(as) && as[end].Package == as[start].Package {
+			end++
 		}
+		m[as[start].Package] = group(as[start:end])
+		start = end
 	}
 	return
 }
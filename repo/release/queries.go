@@ -17,7 +17,24 @@
 package release
 
 // GetRepoArchives fetches all Archives for every Package in a Repo
-const GetRepoArchives = `` // TODO: Add this query
+const GetRepoArchives = `
+WITH ids AS (
+    SELECT archive_id FROM packages
+    INNER JOIN repos ON repos.id = packages.repo_id
+    WHERE repos.name=?
+)
+SELECT archives.* FROM archives
+INNER JOIN ids ON ids.archive_id = archives.id
+`
 
 // GetPkgArchives fetches all Archives for a single Package in a Repo
-const GetPkgArchives = `` // TODO: Add this query
+const GetPkgArchives = `
+WITH ids AS (
+    SELECT archive_id FROM packages
+    INNER JOIN repos ON repos.id = packages.repo_id
+    WHERE repos.name=?
+)
+SELECT archives.* FROM archives
+INNER JOIN ids ON ids.archive_id = archives.id
+WHERE archives.package=?
+`
@@ -27,32 +27,55 @@ type Releases []Release
 
 // GetReleases retrieves all of the Releases for a package in a repo
 func GetReleases(tx *sqlx.Tx, repo, pkg string) (rs Releases, err error) {
-	var as archive.Archives
-	if err = tx.Get(&as, GetPkgArchives, repo, pkg); err != nil {
+	as := make(archive.Archives, 0)
+	if err = tx.Select(&as, GetPkgArchives, repo, pkg); err != nil {
 		return
 	}
 	sort.Sort(as)
-	// Sort Archives into Releases
-	var r *Release
+	rs = group(as)
+	return
+}
+
+// group sorts the Archives of a single package into Releases, where each Delta belongs to the Release it starts from
+func group(as archive.Archives) (rs Releases) {
+	rs = make(Releases, 0)
+	byNumber := make(map[int]int)
 	for _, a := range as {
-		if r == nil {
-			r = &Release{}
-		} else if r.Package() != a.Package {
-			rs = append(rs, *r)
-			r = &Release{}
+		if !a.IsValid() {
+			continue
+		}
+		i, ok := byNumber[a.Release]
+		if !ok {
+			i = len(rs)
+			byNumber[a.Release] = i
+			rs = append(rs, Release{})
 		}
 		if a.IsPackage() {
 			d := a.Copy()
-			r.Pkg = &d
-		} else if a.IsDelta() {
-			r.Deltas = append(r.Deltas, a.Copy())
+			rs[i].Pkg = &d
+		} else {
+			rs[i].Deltas = append(rs[i].Deltas, a.Copy())
 		}
 	}
 	// Sort releases by Release number
+	for i := range rs {
+		rs[i].Sort()
+	}
 	sort.Sort(rs)
 	return
 }
 
+// Archives flattens the Releases back into a list of Archives
+func (rs Releases) Archives() (as archive.Archives) {
+	for _, r := range rs {
+		if r.Pkg != nil {
+			as = append(as, *r.Pkg)
+		}
+		as = append(as, r.Deltas...)
+	}
+	return
+}
+
 // Len returns the length of the Releases
 func (rs Releases) Len() int {
 	return len(rs)
@@ -29,6 +29,8 @@ type Repo struct {
 	ID             int    `db:"id"`
 	Name           string `db:"name"`
 	InstantTransit bool   `db:"instant_transit"`
+
+	journal *journal
 }
 
 // Get retrieves a single repo by name
@@ -86,11 +88,20 @@ func (r *Repo) Summarize(tx *sqlx.Tx) (s Summary, err error) {
 	return
 }
 
+// Path returns the location of this Repo on disk
+func (r *Repo) Path() string {
+	return filepath.Join(config.Current.RepoPath(), r.Name)
+}
+
+// AssetPath returns the location of the assets for this Repo
+func (r *Repo) AssetPath() string {
+	return filepath.Join(config.Current.AssetPath(), r.Name)
+}
+
 // Size returns the used and free space in a Repo's filesystem
 func (r *Repo) Size() (used, free uint64, err error) {
-	rp := filepath.Join(config.Current.RepoPath(), r.Name)
 	var st syscall.Statfs_t
-	if err = syscall.Statfs(rp, &st); err != nil {
+	if err = syscall.Statfs(r.Path(), &st); err != nil {
 		return
 	}
 	free = uint64(st.Bsize) * st.Bavail
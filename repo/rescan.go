@@ -0,0 +1,107 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/getsolus/ferryd/repo/archive"
+	"github.com/jmoiron/sqlx"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// walkArchives calls "fn" for every Archive file found on disk for this Repo
+func (r *Repo) walkArchives(fn func(path, uri string, info os.FileInfo) error) error {
+	root := r.Path()
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip hidden directories, i.e. temporary index dirs
+		if info.IsDir() {
+			if path != root && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || !archive.IsArchiveFile(info.Name()) {
+			return nil
+		}
+		uri, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		return fn(path, uri, info)
+	})
+}
+
+// untracked describes an Archive file which has no DB record, using only its filename
+func untracked(uri string, info os.FileInfo) archive.Archive {
+	a, err := archive.ParseFilename(uri)
+	if err != nil {
+		a = &archive.Archive{
+			URI: uri,
+		}
+	}
+	a.Size = int(info.Size())
+	return *a
+}
+
+// scanFile reads an Archive file from this Repo, saving it to the DB and linking it into the pool.
+// A file which no longer matches its DB record is left alone and reported as a mismatch, since the
+// record and the pool copy may be shared with other Repos.
+func (r *Repo) scanFile(tx *sqlx.Tx, pool *Repo, path, uri string) (a *archive.Archive, status archive.Status, err error) {
+	if a, err = archive.Open(path, uri); err != nil {
+		err = fmt.Errorf("failed to read archive '%s', reason: '%s'", uri, err.Error())
+		return
+	}
+	// Create the Archive record, or make sure it still matches
+	var old archive.Archive
+	switch err = tx.Get(&old, archive.GetByURI, uri); err {
+	case sql.ErrNoRows:
+		status = archive.StatusAdded
+		err = a.Save(tx)
+	case nil:
+		switch {
+		case old.Size != a.Size:
+			status = archive.StatusSizeMismatch
+		case old.Hash != a.Hash:
+			status = archive.StatusHashMismatch
+		}
+		a = &old
+	}
+	if err != nil || status == archive.StatusSizeMismatch || status == archive.StatusHashMismatch {
+		return
+	}
+	// Make sure the pool has a copy of the file
+	if !r.IsPool() {
+		pp := pool.ArchivePath(a)
+		if _, err = os.Stat(pp); os.IsNotExist(err) {
+			err = linkFile(path, pp)
+		}
+		if err != nil {
+			return
+		}
+		if err = pool.addLink(tx, a); err != nil {
+			return
+		}
+	}
+	err = r.addLink(tx, a)
+	return
+}
@@ -17,35 +17,161 @@
 package repo
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"github.com/getsolus/ferryd/core"
 	"github.com/getsolus/ferryd/jobs"
 	"github.com/getsolus/ferryd/manifest"
+	"github.com/getsolus/ferryd/repo/archive"
 	"github.com/getsolus/ferryd/repo/pkgs"
+	"github.com/getsolus/ferryd/repo/release"
+	"github.com/getsolus/libeopkg/index"
 	"github.com/jmoiron/sqlx"
+	"os"
+	"path/filepath"
 )
 
-// Check makes sure the DB matches disk
-func (r *Repo) Check(tx *sqlx.Tx) (d *Diff, err error) {
-	// TODO: Implement
-	return nil, errors.New("Function not implemented")
+// Check makes sure the DB matches disk, without making any changes
+func (r *Repo) Check(tx *sqlx.Tx, j *jobs.Job) (d *Diff, err error) {
+	// Get the current links for this repo
+	as, err := r.Archives(tx)
+	if err != nil {
+		return
+	}
+	linked := make(map[string]archive.Archive)
+	for _, a := range as {
+		linked[a.URI] = a
+	}
+	// Compare every archive on disk with the DB
+	p := j.Progress()
+	p.Phase("files", len(as))
+	d = &Diff{}
+	err = r.walkArchives(func(path, uri string, info os.FileInfo) error {
+		p.Step(info.Size())
+		a, ok := linked[uri]
+		if !ok {
+			j.Log().Warnf("Archive '%s' is not in the DB\n", uri)
+			d.add(untracked(uri, info), archive.StatusUntracked)
+			return nil
+		}
+		delete(linked, uri)
+		if int64(a.Size) != info.Size() {
+			j.Log().Warnf("Archive '%s' is %d bytes on disk, but %d bytes in the DB\n", uri, info.Size(), a.Size)
+			d.add(a, archive.StatusSizeMismatch)
+			return nil
+		}
+		hash, err := core.FileSHA1Sum(path)
+		if err != nil {
+			return err
+		}
+		if hash != a.Hash {
+			j.Log().Warnf("Archive '%s' does not match the hash in the DB\n", uri)
+			d.add(a, archive.StatusHashMismatch)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Anything left over is missing from disk
+	for _, a := range linked {
+		j.Log().Warnf("Archive '%s' is missing from disk\n", a.URI)
+		d.add(a, archive.StatusMissing)
+	}
+	d.sort()
+	return
 }
 
 // Delta generates missing deltas and removes unneeded ones
-func Delta(r *Repo, j *jobs.Job, tx *sqlx.Tx) error {
-	// TODO: Implement
-	return errors.New("Function not implemented")
+func Delta(ctx context.Context, r *Repo, j *jobs.Job, tx *sqlx.Tx) (d *Diff, err error) {
+	if r.IsPool() {
+		return nil, ErrPoolDelta
+	}
+	b, err := newDeltaBuilder(r, j, tx)
+	if err != nil {
+		return
+	}
+	as, err := r.Archives(tx)
+	if err != nil {
+		return
+	}
+	// Count the packages to report progress
+	count := 0
+	for i := range as {
+		if i == 0 || as[i].Package != as[i-1].Package {
+			count++
+		}
+	}
+	p := j.Progress()
+	p.Phase("packages", count)
+	// Archives are sorted, so each package is a contiguous run
+	for start := 0; start < len(as); {
+		// Stop between packages if cancelled
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		end := start
+		var size int64
+		for end < len(as) && as[end].Package == as[start].Package {
+			size += int64(as[end].Size)
+			end++
+		}
+		if err = b.build(as[start:end]); err != nil {
+			return nil, err
+		}
+		p.Step(size)
+		start = end
+	}
+	p.Phase("indexing", 0)
+	return b.publish()
 }
 
 // DeltaPackage generates missing deltas and removes unneeded ones for a single package
-func DeltaPackage(r *Repo, j *jobs.Job, tx *sqlx.Tx) error {
-	// TODO: Implement
-	return errors.New("Function not implemented")
+func DeltaPackage(ctx context.Context, r *Repo, j *jobs.Job, tx *sqlx.Tx) (d *Diff, err error) {
+	if len(j.Pkg) == 0 {
+		return nil, errors.New("job is missing a package")
+	}
+	if r.IsPool() {
+		return nil, ErrPoolDelta
+	}
+	b, err := newDeltaBuilder(r, j, tx)
+	if err != nil {
+		return
+	}
+	as, err := r.Archives(tx)
+	if err != nil {
+		return
+	}
+	var pkg archive.Archives
+	var size int64
+	for _, a := range as {
+		if a.Package == j.Pkg {
+			pkg = append(pkg, a)
+			size += int64(a.Size)
+		}
+	}
+	p := j.Progress()
+	p.Phase("packages", 1)
+	if err = b.build(pkg); err != nil {
+		return nil, err
+	}
+	p.Step(size)
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	p.Phase("indexing", 0)
+	return b.publish()
 }
 
-// Index regenerates the index for a repo
-func Index(r *Repo, j *jobs.Job, tx *sqlx.Tx) error {
-	// TODO: Implement
-	return errors.New("Function not implemented")
+// Index regenerates the index for a repo, keeping the previous one until the changes are kept
+func Index(ctx context.Context, r *Repo, j *jobs.Job, tx *sqlx.Tx) error {
+	j.Progress().Phase("indexing", 0)
+	if err := r.stageIndex(tx); err != nil {
+		return err
+	}
+	j.Log().Infof("Regenerated the index for '%s'\n", r.Name)
+	return nil
 }
 
 // Import adds all of the files in a repo to the DB
@@ -54,44 +180,234 @@ func (r *Repo) Import(tx *sqlx.Tx) error {
 	return errors.New("Function not implemented")
 }
 
-// Link updates the links for a package that has already been updated in the pool and DB
+// Link updates the links for a package that has already been updated in the pool and DB,
+// keeping track of any new files until the changes are kept
 func (r *Repo) Link(tx *sqlx.Tx, diff *Diff) error {
-	// TODO: Implement
-	return errors.New("Function not implemented")
+	if r.IsPool() {
+		return nil
+	}
+	pool, err := Pool(tx)
+	if err != nil {
+		return err
+	}
+	for _, a := range *diff {
+		if a.Status == archive.StatusRemoved {
+			continue
+		}
+		if err = r.stageLink(pool, &a); err != nil {
+			return err
+		}
+		if err = r.addLink(tx, &a); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Remove deletes all of the DB records for this repo
-func Remove(r *Repo, j *jobs.Job, tx *sqlx.Tx) error {
+func Remove(ctx context.Context, r *Repo, j *jobs.Job, tx *sqlx.Tx) error {
+	if r.IsPool() {
+		return ErrPoolDest
+	}
+	as, err := r.Archives(tx)
+	if err != nil {
+		return err
+	}
+	pool, err := Pool(tx)
+	if err != nil {
+		return err
+	}
 	// Remove Packages
-	if _, err := tx.Exec(pkgs.RemoveByRepo, r.ID); err != nil {
+	if _, err = tx.Exec(pkgs.RemoveByRepo, r.ID); err != nil {
 		return err
 	}
+	// Drop any Archives which are no longer needed
+	for i := range as {
+		if err = r.dropOrphan(tx, pool, &as[i]); err != nil {
+			return err
+		}
+	}
 	// Remove Repo record
-	_, err := tx.NamedExec(RemoveRepo, r)
+	_, err = tx.NamedExec(RemoveRepo, r)
 	return err
 }
 
 // Rescan checks for differences between the DB and disk and updated the DB
-func Rescan(r *Repo, j *jobs.Job, tx *sqlx.Tx) error {
-	// TODO: Implement
-	return errors.New("Function not implemented")
+func Rescan(ctx context.Context, r *Repo, j *jobs.Job, tx *sqlx.Tx) (d *Diff, err error) {
+	// Get the current links for this repo
+	as, err := r.Archives(tx)
+	if err != nil {
+		return
+	}
+	linked := make(map[string]archive.Archive)
+	for _, a := range as {
+		linked[a.URI] = a
+	}
+	pool, err := Pool(tx)
+	if err != nil {
+		return
+	}
+	// Add or update every archive on disk
+	p := j.Progress()
+	p.Phase("files", 0)
+	d = &Diff{}
+	err = r.walkArchives(func(path, uri string, info os.FileInfo) error {
+		// Stop between files if cancelled
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		a, status, err := r.scanFile(tx, pool, path, uri)
+		if err != nil {
+			return err
+		}
+		p.Step(info.Size())
+		if _, ok := linked[uri]; !ok && status == archive.StatusUnchanged {
+			status = archive.StatusAdded
+		}
+		delete(linked, uri)
+		switch status {
+		case archive.StatusAdded:
+			j.Log().Infof("Found new archive '%s'\n", uri)
+		case archive.StatusSizeMismatch, archive.StatusHashMismatch:
+			j.Log().Warnf("Archive '%s' no longer matches the DB (%s), leaving it unlinked\n", uri, status)
+		}
+		if status != archive.StatusUnchanged {
+			d.add(*a, status)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Drop the links for files which no longer exist, leaving any orphans in the pool for Trim
+	for _, a := range linked {
+		j.Log().Warnf("Archive '%s' is missing from disk, unlinking it\n", a.URI)
+		if err = r.removeLink(tx, &a); err != nil {
+			return nil, err
+		}
+		d.add(a, archive.StatusRemoved)
+	}
+	d.sort()
+	return
 }
 
-// Transit copies new packages into a repo, creates missing deltas, removes old deltas, and add releases to the DB
+// Transit copies new packages into the pool and adds their releases to the DB
 func (r *Repo) Transit(tx *sqlx.Tx, m *manifest.Manifest) (diff *Diff, err error) {
-	// TODO: Implement
-	err = errors.New("Function not implemented")
+	if !r.IsPool() {
+		return nil, ErrNotPool
+	}
+	var copied []string
+	diff = &Diff{}
+	for _, path := range m.GetPaths() {
+		a, status, added, err := r.transitFile(tx, path)
+		if added {
+			copied = append(copied, r.ArchivePath(a))
+		}
+		if err != nil {
+			// Don't leave behind any files which will not be in the DB
+			for _, p := range copied {
+				os.Remove(p)
+			}
+			return nil, err
+		}
+		diff.add(*a, status)
+	}
+	diff.sort()
 	return
 }
 
 // TrimObsolete removes obsolete packages for a repo
-func TrimObsolete(r *Repo, j *jobs.Job, tx *sqlx.Tx) error {
-	// TODO: Implement
-	return errors.New("Function not implemented")
+func TrimObsolete(ctx context.Context, r *Repo, j *jobs.Job, tx *sqlx.Tx) (d *Diff, err error) {
+	if r.IsPool() {
+		return nil, ErrPoolDest
+	}
+	dist, err := index.NewDistribution(filepath.Join(r.AssetPath(), DistributionFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read distribution for '%s', reason: '%s'", r.Name, err.Error())
+	}
+	as, err := r.Archives(tx)
+	if err != nil {
+		return
+	}
+	var obsolete archive.Archives
+	for _, a := range as {
+		if dist.IsObsolete(a.Package) {
+			obsolete = append(obsolete, a)
+		}
+	}
+	return r.trim(ctx, tx, j, obsolete)
 }
 
 // TrimPackages removes packages which are older than "max" releases from the latest
-func TrimPackages(r *Repo, j *jobs.Job, tx *sqlx.Tx) error {
-	// TODO: Implement
-	return errors.New("Function not implemented")
+func TrimPackages(ctx context.Context, r *Repo, j *jobs.Job, tx *sqlx.Tx) (d *Diff, err error) {
+	if r.IsPool() {
+		return nil, ErrPoolDest
+	}
+	if j.Max < 1 {
+		return nil, errors.New("max releases must be at least 1")
+	}
+	m, err := release.GetAllReleases(tx, r.Name)
+	if err != nil {
+		return
+	}
+	var old archive.Archives
+	for _, rs := range m {
+		// Walk back from the newest release, keeping "max" packages and their deltas
+		kept := make(map[int]bool)
+		for i := len(rs) - 1; i >= 0; i-- {
+			if len(kept) >= j.Max {
+				old = append(old, trimmed(rs[:i+1], kept)...)
+				break
+			}
+			if rs[i].Pkg != nil {
+				kept[rs[i].Pkg.Release] = true
+			}
+		}
+	}
+	return r.trim(ctx, tx, j, old)
+}
+
+// trimmed lists the Archives of old releases, except for the deltas which lead to a kept release,
+// since deltas are filed under the release they start from
+func trimmed(rs release.Releases, kept map[int]bool) (as archive.Archives) {
+	for _, r := range rs {
+		if r.Pkg != nil {
+			as = append(as, *r.Pkg)
+		}
+		for _, delta := range r.Deltas {
+			if !kept[delta.To] {
+				as = append(as, delta)
+			}
+		}
+	}
+	return
+}
+
+// trim removes a list of Archives from this Repo and publishes a new index
+func (r *Repo) trim(ctx context.Context, tx *sqlx.Tx, j *jobs.Job, as archive.Archives) (d *Diff, err error) {
+	pool, err := Pool(tx)
+	if err != nil {
+		return
+	}
+	p := j.Progress()
+	p.Phase("archives", len(as))
+	d = &Diff{}
+	for i := range as {
+		// Stop between archives if cancelled
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		j.Log().Infof("Removing '%s'\n", as[i].URI)
+		if err = r.removeArchive(tx, pool, &as[i]); err != nil {
+			return nil, err
+		}
+		d.add(as[i], archive.StatusRemoved)
+		p.Step(int64(as[i].Size))
+	}
+	p.Phase("indexing", 0)
+	if err = r.stageIndex(tx); err != nil {
+		return nil, err
+	}
+	d.sort()
+	return
 }
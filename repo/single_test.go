@@ -0,0 +1,114 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package repo
+
+import (
+	"github.com/getsolus/ferryd/config"
+	"github.com/getsolus/ferryd/core"
+	"github.com/getsolus/ferryd/jobs"
+	"github.com/getsolus/ferryd/repo/archive"
+	_ "github.com/mattn/go-sqlite3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testConfig points the config at temporary directories for a single test
+func testConfig(t *testing.T) {
+	config.Current = &config.File{
+		BaseDir:  t.TempDir(),
+		BuildDir: t.TempDir(),
+	}
+}
+
+func TestCheck(t *testing.T) {
+	testConfig(t)
+	db := OpenDB()
+	defer db.Close()
+	tx := db.MustBegin()
+	defer tx.Rollback()
+	r := &Repo{Name: "unstable"}
+	if err := r.Create(tx); err != nil {
+		t.Fatalf("Failed to create repo: %v", err)
+	}
+	// write creates an archive file in the repo, returning its size and hash
+	write := func(uri, content string) (int, string) {
+		path := filepath.Join(r.Path(), uri)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create archive dir: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write archive: %v", err)
+		}
+		hash, err := core.FileSHA1Sum(path)
+		if err != nil {
+			t.Fatalf("Failed to hash archive: %v", err)
+		}
+		return len(content), hash
+	}
+	// link records an archive in the DB and links it to the repo
+	link := func(uri string, size int, hash string) {
+		a, err := archive.ParseFilename(uri)
+		if err != nil {
+			t.Fatalf("Failed to parse archive filename: %v", err)
+		}
+		a.Size = size
+		a.Hash = hash
+		if err = a.Save(tx); err != nil {
+			t.Fatalf("Failed to save archive: %v", err)
+		}
+		if err = r.addLink(tx, a); err != nil {
+			t.Fatalf("Failed to link archive: %v", err)
+		}
+	}
+	// Matches the DB
+	size, hash := write("n/nano/nano-4.5-116-1-x86_64.eopkg", "nano")
+	link("n/nano/nano-4.5-116-1-x86_64.eopkg", size, hash)
+	// Only in the DB
+	link("n/nano/nano-4.6-117-1-x86_64.eopkg", 4, hash)
+	// Only on disk
+	write("n/nano/nano-4.7-118-1-x86_64.eopkg", "nano")
+	// Same hash, different size
+	size, hash = write("v/vim/vim-8.2-200-1-x86_64.eopkg", "vim")
+	link("v/vim/vim-8.2-200-1-x86_64.eopkg", size+1, hash)
+	// Same size, different hash
+	size, _ = write("z/zsh/zsh-5.8-50-1-x86_64.eopkg", "zsh")
+	link("z/zsh/zsh-5.8-50-1-x86_64.eopkg", size, hash)
+	d, err := r.Check(tx, &jobs.Job{})
+	if err != nil {
+		t.Fatalf("Failed to check repo: %v", err)
+	}
+	expected := map[string]archive.Status{
+		"n/nano/nano-4.6-117-1-x86_64.eopkg": archive.StatusMissing,
+		"n/nano/nano-4.7-118-1-x86_64.eopkg": archive.StatusUntracked,
+		"v/vim/vim-8.2-200-1-x86_64.eopkg":   archive.StatusSizeMismatch,
+		"z/zsh/zsh-5.8-50-1-x86_64.eopkg":    archive.StatusHashMismatch,
+	}
+	if len(*d) != len(expected) {
+		t.Fatalf("Expected %d problems, found %d", len(expected), len(*d))
+	}
+	for _, a := range *d {
+		status, ok := expected[a.URI]
+		if !ok {
+			t.Fatalf("Unexpected problem with archive '%s': %s", a.URI, a.Status)
+		}
+		if a.Status != status {
+			t.Fatalf("Archive '%s' should be '%s', found '%s'", a.URI, status, a.Status)
+		}
+	}
+}
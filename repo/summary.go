@@ -17,7 +17,9 @@
 package repo
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/gob"
 	"fmt"
 	"io"
 )
@@ -32,6 +34,27 @@ type Summary struct {
 	Free        uint64        `json:"free"`
 }
 
+// summaryData has the same fields as a Summary, without its methods
+type summaryData Summary
+
+// MarshalBinary converts a Summary to its Gob encoded form
+func (s *Summary) MarshalBinary() (data []byte, err error) {
+	buff := bytes.NewBuffer([]byte{})
+	enc := gob.NewEncoder(buff)
+	// Encode without methods, otherwise gob calls MarshalBinary again
+	if err = enc.Encode((*summaryData)(s)); err == nil {
+		data = buff.Bytes()
+	}
+	return
+}
+
+// UnmarshalBinary converts a Gob encoded Summary back to its useful form
+func (s *Summary) UnmarshalBinary(data []byte) error {
+	buff := bytes.NewBuffer(data)
+	dec := gob.NewDecoder(buff)
+	return dec.Decode((*summaryData)(s))
+}
+
 // Print writes out a Summary in a human-readable format
 func (s *Summary) Print(out io.Writer, single bool) {
 	// Don't try to print a null summary
@@ -0,0 +1,106 @@
+//
+// Copyright © 2017-2020 Solus Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/getsolus/ferryd/repo/archive"
+	"github.com/jmoiron/sqlx"
+	"os"
+	"path/filepath"
+)
+
+// ErrNotPool is returned when an operation that only applies to the pool is used on another Repo
+var ErrNotPool = errors.New("operation is only supported for the pool")
+
+// poolURI determines the location of an Archive within a repo, i.e. "n/nano/nano-4.5-116-1-x86_64.eopkg"
+func poolURI(path string, a *archive.Archive) (uri string, err error) {
+	meta, err := a.Metadata()
+	if err != nil {
+		return
+	}
+	if len(meta.Source.Name) == 0 {
+		err = fmt.Errorf("archive '%s' is missing a source name", filepath.Base(path))
+		return
+	}
+	// The path component is based on the source name of the package
+	pkg := *meta.Package
+	pkg.Source = meta.Source
+	uri = filepath.Join(pkg.GetPathComponent(), filepath.Base(path))
+	return
+}
+
+// findByName looks for an existing Archive with the same filename
+func findByName(tx *sqlx.Tx, name string) (a *archive.Archive, err error) {
+	var as archive.Archives
+	if err = tx.Select(&as, archive.FindByName, "%"+name); err != nil {
+		return
+	}
+	for _, found := range as {
+		if filepath.Base(found.URI) == name {
+			a = &found
+			return
+		}
+	}
+	return
+}
+
+// transitFile adds a single incoming Archive file to the pool, reporting if a new file was written
+func (r *Repo) transitFile(tx *sqlx.Tx, path string) (a *archive.Archive, status archive.Status, copied bool, err error) {
+	if a, err = archive.Open(path, ""); err != nil {
+		err = fmt.Errorf("failed to read archive '%s', reason: '%s'", filepath.Base(path), err.Error())
+		return
+	}
+	if a.URI, err = poolURI(path, a); err != nil {
+		return
+	}
+	// Package IDs must be unique
+	existing, err := findByName(tx, filepath.Base(path))
+	if err != nil {
+		return
+	}
+	if existing != nil {
+		if existing.Hash != a.Hash {
+			err = fmt.Errorf("archive '%s' already exists with a different hash", filepath.Base(path))
+			return
+		}
+		a = existing
+		status = archive.StatusUnchanged
+	} else {
+		status = archive.StatusAdded
+	}
+	// Copy the file into the pool if missing
+	dst := r.ArchivePath(a)
+	if _, err = os.Stat(dst); os.IsNotExist(err) {
+		if err = linkFile(path, dst); err != nil {
+			return
+		}
+		copied = true
+	}
+	if err != nil {
+		return
+	}
+	// Save the DB records
+	if a.ID == 0 {
+		if err = a.Save(tx); err != nil {
+			return
+		}
+	}
+	err = r.addLink(tx, a)
+	return
+}
@@ -37,7 +37,7 @@ func CopyDir(source, dest string, recursive bool) error {
 	for _, file := range files {
 		// Generate the filepaths
 		srcFile := filepath.Join(source, file.Name())
-		dstFile := filepath.Join(source, file.Name())
+		dstFile := filepath.Join(dest, file.Name())
 		// Check for a directory
 		if file.IsDir() {
 			// handle recursion